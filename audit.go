@@ -0,0 +1,119 @@
+package mkcert
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditFileName is the name of the audit log Audit appends to in the CAROOT.
+const AuditFileName = "audit.jsonl"
+
+// AuditRecord is a line of an audit log, recording a certificate issued.
+type AuditRecord struct {
+	// Time is when the certificate was issued.
+	Time time.Time `json:"time"`
+	// Label is the label given to Audit, such as the name of the tool.
+	Label string `json:"label,omitempty"`
+	// Domains the certificate covers.
+	Domains []string `json:"domains"`
+	// Serial is the certificate's serial number, in hex.
+	Serial string `json:"serial"`
+	// NotAfter is when the certificate expires.
+	NotAfter time.Time `json:"not_after"`
+	// CertFile and KeyFile are the absolute paths the certificate and key
+	// were written to.
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// CARoot is the directory of the CA which issued the certificate.
+	CARoot string `json:"caroot"`
+	// Usages are the extended key usages of the certificate.
+	Usages []string `json:"usages,omitempty"`
+	// Backend is what issued the certificate, "mkcert" for the mkcert CLI.
+	Backend string `json:"backend"`
+}
+
+// Audit has Exec append an AuditRecord for each certificate it issues to the
+// audit log, audit.jsonl in the CAROOT unless AuditFile is given, so that what
+// the CA has signed can be reviewed later. Certificates which are reused
+// aren't recorded again. label is recorded with each line, and may be blank.
+// If the record can't be written, Exec returns an error, though the
+// certificate has been issued. List reads the log back.
+func Audit(label string) Opt {
+	return func(p *params) {
+		p.Audit = true
+		p.AuditLabel = label
+	}
+}
+
+// AuditFile has Audit append to the file at path rather than the CAROOT,
+// such as to keep a log of the certificates issued by several CAs. It
+// enables Audit if it's not been given.
+func AuditFile(path string) Opt {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return func(p *params) {
+		p.Audit = true
+		p.AuditFile = path
+	}
+}
+
+// auditMu serializes writes to audit logs by this process.
+var auditMu sync.Mutex
+
+// audit appends the record of cert, issued as leaf, to the audit log for p.
+func audit(p params, cert Cert, leaf *x509.Certificate) error {
+	rec := AuditRecord{
+		Time:     time.Now().UTC(),
+		Label:    p.AuditLabel,
+		Domains:  cert.Domains,
+		Serial:   fmt.Sprintf("%x", leaf.SerialNumber),
+		NotAfter: leaf.NotAfter.UTC(),
+		CertFile: absPath(cert.File),
+		KeyFile:  absPath(cert.KeyFile),
+		CARoot:   cert.CARoot,
+		Usages:   cert.Usages,
+		Backend:  "mkcert",
+	}
+	if rec.CARoot == "" {
+		var err error
+		if rec.CARoot, err = findCARoot(p); err != nil {
+			return err
+		}
+	}
+	path := p.AuditFile
+	if path == "" {
+		path = filepath.Join(rec.CARoot, AuditFileName)
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("mkcert: audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("mkcert: audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("mkcert: audit log: %w", err)
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
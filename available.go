@@ -0,0 +1,48 @@
+package mkcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+)
+
+// Available checks that mkcert can be used: that the binary is found, reports
+// a version, and that its CAROOT can be read if it exists. It returns the
+// version, such as "v1.4.1", or an error describing what's wrong.
+func Available(opts ...Opt) (version string, err error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	if _, err := binary(p); err != nil {
+		return "", err
+	}
+
+	out, err := run(p, "-version")
+	if err != nil {
+		return "", err
+	}
+	version = string(bytes.TrimSpace(out))
+	if !versionRe.MatchString(version) {
+		return "", fmt.Errorf("mkcert: unrecognised version %q", version)
+	}
+
+	caroot, err := findCARoot(p)
+	if err != nil {
+		return "", err
+	}
+	if caroot == "" {
+		return "", errors.New("mkcert: no CAROOT")
+	}
+	// mkcert creates the CAROOT when it's missing, so only complain about one
+	// we can't read.
+	if _, err := ioutil.ReadDir(caroot); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("mkcert: %w", err)
+	}
+	return version, nil
+}
+
+var versionRe = regexp.MustCompile(`^v?\d+\.\d+`)
@@ -0,0 +1,249 @@
+package mkcert
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrBinaryNotFound is matched, using errors.Is, by the error returned when
+// the mkcert program can't be found. The error suggests how to install it.
+var ErrBinaryNotFound = errors.New("mkcert: mkcert not found")
+
+// binaryNotFoundError is the error for ErrBinaryNotFound.
+type binaryNotFoundError struct {
+	bin string
+	err error
+}
+
+func (e *binaryNotFoundError) Error() string {
+	return fmt.Sprintf("mkcert: %s not found, %s, or give its path with Binary", e.bin, installHint())
+}
+
+func (e *binaryNotFoundError) Unwrap() error { return e.err }
+
+func (e *binaryNotFoundError) Is(target error) bool { return target == ErrBinaryNotFound }
+
+// installHint suggests how to install mkcert on this platform.
+func installHint() string {
+	return fmt.Sprintf("install it with %q", installCommand())
+}
+
+// installCommand returns the command installing mkcert on this machine,
+// with the package manager found, or else by building it with Go.
+func installCommand() string {
+	if runtime.GOOS == "darwin" {
+		return "brew install mkcert"
+	}
+	for _, pm := range []struct{ bin, cmd string }{
+		{"scoop", "scoop bucket add extras; scoop install mkcert"},
+		{"choco", "choco install mkcert"},
+		{"brew", "brew install mkcert"},
+		{"apt", "apt install mkcert"},
+		{"pacman", "pacman -S mkcert"},
+	} {
+		if _, err := exec.LookPath(pm.bin); err == nil {
+			return pm.cmd
+		}
+	}
+	return "go install filippo.io/mkcert@latest"
+}
+
+// BinaryNames are glob patterns, as for filepath.Match, for the names mkcert
+// may be installed under other than "mkcert", such as the release names it's
+// downloaded as. When mkcert isn't in PATH, and Binary isn't used, the
+// directories in PATH are searched for files matching the patterns, and the
+// one with the highest version in its name is run. Patterns with a directory,
+// such as "/opt/tools/mkcert-*", are matched there rather than in PATH.
+// Defaults to the release name for this platform, such as
+// "mkcert-v*-linux-amd64".
+func BinaryNames(patterns ...string) Opt {
+	return func(p *params) { p.BinaryNames = patterns }
+}
+
+// releaseName is the pattern of the name of the mkcert release for this
+// platform.
+func releaseName() string {
+	name := "mkcert-v*-" + runtime.GOOS + "-" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// binary returns the mkcert program to run for p. If it's not in PATH, it's
+// looked for under the BinaryNames. On Windows, where mkcert is usually
+// installed with Scoop or Chocolatey, the places they install it are tried
+// too, as some programs aren't started with their directories in PATH.
+// Scoop's shims are resolved to the program they run.
+func binary(p params) (string, error) {
+	bin := p.Binary
+	if bin == "" {
+		bin = "mkcert"
+	}
+	path, err := exec.LookPath(bin)
+	if err == nil {
+		if runtime.GOOS == "windows" {
+			path = resolveShim(path)
+		}
+		return path, nil
+	}
+	if p.Binary != "" {
+		return "", &binaryNotFoundError{bin: bin, err: err}
+	}
+	names := p.BinaryNames
+	if names == nil {
+		names = []string{releaseName()}
+	}
+	if path := findRelease(names, filepath.SplitList(os.Getenv("PATH"))); path != "" {
+		return path, nil
+	}
+	if runtime.GOOS == "windows" {
+		for _, c := range windowsCandidates(os.Getenv) {
+			if fi, err := os.Stat(c); err == nil && fi.Mode().IsRegular() {
+				return resolveShim(c), nil
+			}
+		}
+	}
+	return "", &binaryNotFoundError{bin: bin, err: err}
+}
+
+// findRelease returns the executable matching one of patterns, in dirs
+// unless the pattern has a directory of its own, with the highest version in
+// its name, or "" if there's none.
+func findRelease(patterns, dirs []string) string {
+	var best string
+	var bestVersion []int
+	for _, pattern := range patterns {
+		globs := []string{pattern}
+		if filepath.Base(pattern) == pattern {
+			globs = globs[:0]
+			for _, dir := range dirs {
+				if dir != "" {
+					globs = append(globs, filepath.Join(dir, pattern))
+				}
+			}
+		}
+		for _, glob := range globs {
+			matches, _ := filepath.Glob(glob)
+			for _, m := range matches {
+				fi, err := os.Stat(m)
+				if err != nil || !fi.Mode().IsRegular() || (runtime.GOOS != "windows" && fi.Mode()&0111 == 0) {
+					continue
+				}
+				v := nameVersion(filepath.Base(m))
+				if best == "" || compareVersions(v, bestVersion) > 0 {
+					best, bestVersion = m, v
+				}
+			}
+		}
+	}
+	return best
+}
+
+var nameVersionRe = regexp.MustCompile(`v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// nameVersion returns the numbers of the version in name, such as [1 4 4]
+// for mkcert-v1.4.4-linux-amd64, or nil if there's none.
+func nameVersion(name string) []int {
+	m := nameVersionRe.FindStringSubmatch(name)
+	if m == nil {
+		return nil
+	}
+	var v []int
+	for _, s := range m[1:] {
+		n, _ := strconv.Atoi(s)
+		v = append(v, n)
+	}
+	return v
+}
+
+// compareVersions returns 1, 0 or -1 as a is higher than, the same as or
+// lower than b.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x > y:
+			return 1
+		case x < y:
+			return -1
+		}
+	}
+	return 0
+}
+
+// windowsCandidates returns where Scoop, Chocolatey and winget install
+// mkcert.exe, in the order they're tried.
+func windowsCandidates(getenv func(string) string) []string {
+	home := getenv("USERPROFILE")
+	programData := getenv("ProgramData")
+	if programData == "" {
+		programData = `C:\ProgramData`
+	}
+	scoop := getenv("SCOOP")
+	if scoop == "" && home != "" {
+		scoop = filepath.Join(home, "scoop")
+	}
+	scoopGlobal := getenv("SCOOP_GLOBAL")
+	if scoopGlobal == "" {
+		scoopGlobal = filepath.Join(programData, "scoop")
+	}
+	choco := getenv("ChocolateyInstall")
+	if choco == "" {
+		choco = filepath.Join(programData, "chocolatey")
+	}
+
+	var dirs []string
+	if scoop != "" {
+		dirs = append(dirs, filepath.Join(scoop, "shims"), filepath.Join(scoop, "apps", "mkcert", "current"))
+	}
+	dirs = append(dirs,
+		filepath.Join(scoopGlobal, "shims"),
+		filepath.Join(scoopGlobal, "apps", "mkcert", "current"),
+		filepath.Join(choco, "bin"),
+		filepath.Join(choco, "lib", "mkcert", "tools"),
+	)
+	if local := getenv("LOCALAPPDATA"); local != "" {
+		dirs = append(dirs, filepath.Join(local, "Microsoft", "WinGet", "Links"))
+	}
+	candidates := make([]string, len(dirs))
+	for i, dir := range dirs {
+		candidates[i] = filepath.Join(dir, "mkcert.exe")
+	}
+	return candidates
+}
+
+// resolveShim returns the program run by the Scoop shim at path, as given by
+// the path in the .shim file alongside it, or path itself if it isn't a
+// shim.
+func resolveShim(path string) string {
+	b, err := ioutil.ReadFile(strings.TrimSuffix(path, filepath.Ext(path)) + ".shim")
+	if err != nil {
+		return path
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "path" {
+			continue
+		}
+		target := strings.Trim(strings.TrimSpace(value), `"`)
+		if _, err := os.Stat(target); err == nil {
+			return target
+		}
+	}
+	return path
+}
@@ -0,0 +1,75 @@
+package mkcert
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// systemBundles are where the system roots are found as a PEM bundle, in the
+// order Go's crypto/x509 looks for them.
+var systemBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian, Ubuntu, Gentoo, Arch
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora, RHEL 6
+	"/etc/ssl/ca-bundle.pem",                            // OpenSUSE
+	"/etc/pki/tls/cacert.pem",                           // OpenELEC
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // CentOS, RHEL 7
+	"/etc/ssl/cert.pem",                                 // Alpine, macOS, BSDs
+}
+
+// WriteBundle writes a PEM bundle of the system roots and the mkcert root CA
+// to path, for tools which accept a single CA bundle and would otherwise
+// lose trust in public sites if given rootCA.pem alone. The system roots are
+// read from SSL_CERT_FILE or the usual bundle locations, so on Windows, which
+// has none, the bundle is only the mkcert root.
+//
+// The file is only rewritten, atomically, if its contents would change, so
+// WriteBundle can be called whenever either set of roots may have changed,
+// such as on startup. It reports whether the file was written.
+func WriteBundle(path string, opts ...Opt) (changed bool, err error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	trust, err := trustStatus(p)
+	if err != nil {
+		return false, err
+	}
+	rootPEM, err := ioutil.ReadFile(filepath.Join(trust.CARoot, "rootCA.pem"))
+	if err != nil {
+		return false, err
+	}
+
+	bundle := mergeBundle(rootPEM)
+	if old, err := ioutil.ReadFile(path); err == nil && bytes.Equal(old, bundle) {
+		return false, nil
+	}
+	return true, WriteFileAtomic(path, bundle, 0644)
+}
+
+// mergeBundle returns the system roots followed by rootPEM.
+func mergeBundle(rootPEM []byte) []byte {
+	paths := systemBundles
+	if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+		paths = []string{f}
+	}
+	var b bytes.Buffer
+	for _, path := range paths {
+		system, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if bytes.Contains(system, bytes.TrimSpace(rootPEM)) {
+			// The system roots already include the CA.
+			return system
+		}
+		b.Write(system)
+		if !bytes.HasSuffix(system, []byte("\n")) {
+			b.WriteByte('\n')
+		}
+		break
+	}
+	b.Write(rootPEM)
+	return b.Bytes()
+}
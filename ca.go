@@ -0,0 +1,95 @@
+package mkcert
+
+import (
+	"context"
+	"crypto/x509"
+	"path/filepath"
+)
+
+// CA is an mkcert root CA kept in a directory of its own, from which
+// certificates can be issued. Unlike the CA given by the CAROOT envvar, any
+// number of them can be used at once, such as one per project or
+// environment.
+type CA struct {
+	// Dir is the absolute path of the CA's CAROOT.
+	Dir string
+}
+
+// OpenCA returns the CA in dir, having mkcert create it if it doesn't exist.
+func OpenCA(dir string) (*CA, error) {
+	ca := &CA{Dir: dir}
+	if abs, err := filepath.Abs(dir); err == nil {
+		ca.Dir = abs
+	}
+	if _, err := ca.TrustStatus(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// Opt returns the option using ca, for functions taking options without a
+// counterpart on CA, such as those in package integrate.
+func (ca *CA) Opt() Opt {
+	return CARoot(ca.Dir)
+}
+
+// with returns opts using ca, in place of any CARoot they give.
+func (ca *CA) with(opts []Opt) []Opt {
+	return append(append([]Opt(nil), opts...), ca.Opt())
+}
+
+// RootFile returns the path of the root certificate of ca.
+func (ca *CA) RootFile() string {
+	return filepath.Join(ca.Dir, "rootCA.pem")
+}
+
+// Root returns the root certificate of ca.
+func (ca *CA) Root() (*x509.Certificate, error) {
+	return readCert(ca.RootFile())
+}
+
+// Exec is like the Exec function, issuing the certificate from ca.
+func (ca *CA) Exec(opts ...Opt) (Cert, error) {
+	return Exec(ca.with(opts)...)
+}
+
+// Manager returns a Manager issuing certificates from ca, with opts.
+func (ca *CA) Manager(opts ...Opt) *Manager {
+	return &Manager{Opts: ca.with(opts)}
+}
+
+// TrustStatus is like the TrustStatus function, for ca.
+func (ca *CA) TrustStatus(opts ...Opt) (Trust, error) {
+	return TrustStatus(ca.with(opts)...)
+}
+
+// Install is like the Install function, installing ca.
+func (ca *CA) Install(opts ...Opt) (Trust, error) {
+	return Install(ca.with(opts)...)
+}
+
+// WriteBundle is like the WriteBundle function, bundling ca with the system
+// roots.
+func (ca *CA) WriteBundle(path string, opts ...Opt) (changed bool, err error) {
+	return WriteBundle(path, ca.with(opts)...)
+}
+
+// Run is like the Run function, with the command trusting ca.
+func (ca *CA) Run(ctx context.Context, argv []string, opts ...Opt) error {
+	return Run(ctx, argv, ca.with(opts)...)
+}
+
+// Revoke is like the Revoke function, revoking the certificate issued by ca.
+func (ca *CA) Revoke(serial string, opts ...Opt) error {
+	return Revoke(serial, ca.with(opts)...)
+}
+
+// CRL is like the CRL function, returning the CRL of ca.
+func (ca *CA) CRL(opts ...Opt) ([]byte, error) {
+	return CRL(ca.with(opts)...)
+}
+
+// OCSP is like the OCSP function, answering for ca.
+func (ca *CA) OCSP(request []byte, opts ...Opt) ([]byte, error) {
+	return OCSP(request, ca.with(opts)...)
+}
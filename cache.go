@@ -0,0 +1,60 @@
+package mkcert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cacheVersion is the layout of the certificates kept in a Directory for
+// Reuse, recorded in its cacheVersionFile. If the file names or formats
+// change, bump it and teach migrateCache to bring older directories up to
+// date, so that certificates in an old layout are never mistaken for current
+// ones.
+//
+// Version 1 is the files named by DefaultFiles, with normalized domains,
+// unless CertFile and KeyFile are given. Directories without a version are
+// from before it was recorded, and share the layout.
+const cacheVersion = 1
+
+const cacheVersionFile = ".mkcert-cache"
+
+// cacheUsable reports whether the certificates in dir can be reused,
+// stamping them with the current version if they're unrecorded. Directories
+// written by a later version of this package aren't used, but regenerated.
+func cacheUsable(dir string) bool {
+	v, err := readCacheVersion(dir)
+	switch {
+	case err != nil:
+		return false
+	case v == cacheVersion:
+		return true
+	case v > cacheVersion:
+		return false
+	}
+	// Version 0 shares the layout of version 1.
+	return stampCache(dir) == nil
+}
+
+// readCacheVersion returns the cacheVersion dir was written with, or 0 if
+// it's unrecorded.
+func readCacheVersion(dir string) (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, cacheVersionFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// stampCache records that dir uses the current cacheVersion, unless it's
+// stamped with a later version, which is left as it is.
+func stampCache(dir string) error {
+	if v, err := readCacheVersion(dir); err == nil && v >= cacheVersion {
+		return nil
+	}
+	return ioutil.WriteFile(filepath.Join(dir, cacheVersionFile), []byte(strconv.Itoa(cacheVersion)+"\n"), 0644)
+}
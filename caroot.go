@@ -0,0 +1,135 @@
+package mkcert
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// CARoot runs mkcert with its CAROOT envvar set to dir, so that it uses (or
+// creates) the CA there rather than the one given by the environment. Unlike
+// setting the envvar, this is safe for concurrent use such as parallel tests,
+// each with their own CA. A relative dir is relative to the current
+// directory, rather than Directory.
+//
+// Tests sharing a CA, whether the default one or a CARoot, are also safe to
+// run in parallel, including from other processes such as the test binaries
+// of other packages: the first runs of mkcert hold a lock file in the CAROOT
+// until the CA's been created, so that they don't each create their own.
+func CARoot(dir string) Opt {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return func(p *params) { p.CARoot = dir }
+}
+
+// env returns the variables to set for mkcert on top of the inherited
+// environment.
+func (p params) env() []string {
+	var env []string
+	if p.CARoot != "" {
+		env = append(env, "CAROOT="+p.CARoot)
+	}
+	if p.trustStores != "" {
+		env = append(env, "TRUST_STORES="+p.trustStores)
+	}
+	return env
+}
+
+// getenv returns the value of the envvar name as mkcert will see it.
+func (p params) getenv(name string) string {
+	switch {
+	case name == "CAROOT" && p.CARoot != "":
+		return p.CARoot
+	case name == "TRUST_STORES" && p.trustStores != "":
+		return p.trustStores
+	}
+	return os.Getenv(name)
+}
+
+// caLocks serializes mkcert runs in this process which will create a CA, as
+// concurrent runs would each create their own and overwrite the others'
+// files. Runs in other processes, such as other packages' tests, are
+// serialized by lockFile.
+var caLocks struct {
+	sync.Mutex
+	dirs map[string]*sync.Mutex
+}
+
+// caLockFile is the file in CAROOT locked while mkcert may create the CA, or
+// while Revoke records a revocation.
+const caLockFile = ".mkcert-lock"
+
+// lockCA prevents other runs of mkcert, in this process or others, creating
+// the CA used by p, returning the function to unlock it. It doesn't lock
+// anything if the CA exists. If the lock file can't be created, such as in a
+// read-only CAROOT, only runs in this process are locked out.
+func lockCA(p params) (unlock func()) {
+	dir := p.getenv("CAROOT")
+	if dir == "" {
+		dir = defaultCARoot()
+	}
+	if dir == "" {
+		return func() {}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "rootCA.pem")); err == nil {
+		return func() {}
+	}
+	return lockDir(dir)
+}
+
+// lockDir takes the lock on the CAROOT dir, held by other runs in this
+// process or others while they change it, returning the function to unlock
+// it. If the lock file can't be created, only runs in this process are
+// locked out.
+func lockDir(dir string) (unlock func()) {
+	dir = filepath.Clean(dir)
+	caLocks.Lock()
+	mu, ok := caLocks.dirs[dir]
+	if !ok {
+		if caLocks.dirs == nil {
+			caLocks.dirs = make(map[string]*sync.Mutex)
+		}
+		mu = new(sync.Mutex)
+		caLocks.dirs[dir] = mu
+	}
+	caLocks.Unlock()
+	mu.Lock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return mu.Unlock
+	}
+	unlockFile, err := lockFile(filepath.Join(dir, caLockFile))
+	if err != nil {
+		return mu.Unlock
+	}
+	return func() {
+		unlockFile()
+		mu.Unlock()
+	}
+}
+
+// defaultCARoot returns the CAROOT mkcert uses when the envvar isn't set, as
+// mkcert works it out.
+func defaultCARoot() string {
+	var dir string
+	switch {
+	case runtime.GOOS == "windows":
+		dir = os.Getenv("LocalAppData")
+	case os.Getenv("XDG_DATA_HOME") != "":
+		dir = os.Getenv("XDG_DATA_HOME")
+	case runtime.GOOS == "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			dir = filepath.Join(home, "Library", "Application Support")
+		}
+	default:
+		if home := os.Getenv("HOME"); home != "" {
+			dir = filepath.Join(home, ".local", "share")
+		}
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "mkcert")
+}
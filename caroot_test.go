@@ -0,0 +1,101 @@
+package mkcert_test
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/icio/mkcert"
+	"github.com/icio/mkcert/mkcerttest"
+)
+
+// TestConcurrentCACreation has several processes, each running mkcert
+// several times at once, issue the first certificates from a CA which doesn't
+// exist yet, and checks that they all share the one CA. Run it with -race.
+func TestConcurrentCACreation(t *testing.T) {
+	const procs, runs = 4, 3
+
+	if dir := os.Getenv("MKCERT_TEST_ISSUE_DIR"); dir != "" {
+		// We're one of the processes started below.
+		var wg sync.WaitGroup
+		errs := make([]error, runs)
+		for i := 0; i < runs; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = mkcert.Exec(
+					mkcert.Binary(os.Getenv("MKCERT_TEST_BINARY")),
+					mkcert.CARoot(os.Getenv("MKCERT_TEST_CAROOT")),
+					mkcert.Directory(dir),
+					mkcert.Domains(fmt.Sprintf("%s-%d.test", os.Getenv("MKCERT_TEST_NAME"), i)),
+				)
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		return
+	}
+
+	bin := mkcerttest.FakeBinary(t)
+	caroot := filepath.Join(t.TempDir(), "ca")
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < procs; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestConcurrentCACreation$")
+		cmd.Env = append(os.Environ(),
+			"MKCERT_TEST_ISSUE_DIR="+dir,
+			"MKCERT_TEST_BINARY="+bin,
+			"MKCERT_TEST_CAROOT="+caroot,
+			fmt.Sprintf("MKCERT_TEST_NAME=p%d", i),
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%v\n%s", err, out)
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	roots := x509.NewCertPool()
+	rootPEM, err := ioutil.ReadFile(filepath.Join(caroot, "rootCA.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots.AppendCertsFromPEM(rootPEM)
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, f := range files {
+		if strings.HasSuffix(f, "-key.pem") {
+			continue
+		}
+		n++
+		cert, err := mkcert.Cert{File: f, CARoot: caroot}.Chain()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cert[0].Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+			t.Errorf("%s: %v", filepath.Base(f), err)
+		}
+	}
+	if n != procs*runs {
+		t.Errorf("found %d certificates, want %d", n, procs*runs)
+	}
+}
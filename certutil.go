@@ -0,0 +1,60 @@
+package mkcert
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// CertutilError is returned when the CA can't be installed in or checked
+// against the nss store because certutil, from the NSS tools, is missing.
+type CertutilError struct {
+	// CARoot is the mkcert directory containing the root CA.
+	CARoot string
+	// Install is the command to install certutil, as suggested by mkcert
+	// if it did, or else for the package manager found on this machine. It's
+	// blank if there's no known package.
+	Install string
+}
+
+func (e *CertutilError) Error() string {
+	if e.Install == "" {
+		return fmt.Sprintf("mkcert: CA at %s not trusted by the nss store, install certutil from the NSS tools and run mkcert -install", e.CARoot)
+	}
+	return fmt.Sprintf("mkcert: CA at %s not trusted by the nss store, run %q then mkcert -install", e.CARoot, e.Install)
+}
+
+// hasCertutil reports whether mkcert will find certutil.
+func hasCertutil() bool {
+	if runtime.GOOS == "darwin" {
+		// mkcert also checks Homebrew's keg-only nss.
+		for _, path := range []string{"/usr/local/opt/nss/bin/certutil", "/opt/homebrew/opt/nss/bin/certutil"} {
+			if _, err := os.Stat(path); err == nil {
+				return true
+			}
+		}
+	}
+	_, err := exec.LookPath("certutil")
+	return err == nil
+}
+
+// certutilInstall returns the command installing certutil on this machine.
+func certutilInstall() string {
+	if runtime.GOOS == "darwin" {
+		return "brew install nss"
+	}
+	for _, pm := range []struct{ bin, cmd string }{
+		{"apt", "apt install libnss3-tools"},
+		{"dnf", "dnf install nss-tools"},
+		{"yum", "yum install nss-tools"},
+		{"zypper", "zypper install mozilla-nss-tools"},
+		{"pacman", "pacman -S nss"},
+		{"apk", "apk add nss-tools"},
+	} {
+		if _, err := exec.LookPath(pm.bin); err == nil {
+			return pm.cmd
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package mkcert
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+)
+
+// chainCache holds the chain loaded by Cert.Chain, shared by the copies of a
+// Cert returned by Exec.
+type chainCache struct {
+	mu    sync.Mutex
+	chain []*x509.Certificate
+}
+
+// Chain returns the certificate chain, from the certificate in File to the
+// root CA in CARoot, such as for a TLS stack wanting the whole chain in one
+// place, or for pinning. The chain is loaded the first time it's needed, and
+// kept for the Cert returned by Exec and its copies. The slice shouldn't be
+// modified.
+func (c Cert) Chain() ([]*x509.Certificate, error) {
+	if c.chain == nil {
+		return loadChain(c)
+	}
+	c.chain.mu.Lock()
+	defer c.chain.mu.Unlock()
+	if c.chain.chain == nil {
+		chain, err := loadChain(c)
+		if err != nil {
+			return nil, err
+		}
+		c.chain.chain = chain
+	}
+	return c.chain.chain, nil
+}
+
+// loadChain reads the certificates in the File of c, followed by the root CA
+// in its CARoot. mkcert doesn't write the root CA into File, but it isn't
+// repeated if it's there.
+func loadChain(c Cert) ([]*x509.Certificate, error) {
+	chain, err := readCerts(c.File)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := readCerts(filepath.Join(c.CARoot, "rootCA.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if root := roots[0]; !chain[len(chain)-1].Equal(root) {
+		chain = append(chain, root)
+	}
+	return chain, nil
+}
+
+// readCerts parses the certificates in the PEM file at path, of which there
+// must be at least one.
+func readCerts(path string) ([]*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("mkcert: %w", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("mkcert: %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("mkcert: no certificate in " + path)
+	}
+	return certs, nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/icio/mkcert"
+)
+
+// install runs mkcert -install, through mkcert.Install with opts, for the
+// required stores of rep which are missing the CA, explaining what's about to
+// happen and what to do about anything which went wrong. It returns the
+// report after installation.
+func install(rep report, stores string, yes bool, keychain string, opts []mkcert.Opt) report {
+	var missing []store
+	for _, s := range rep.Stores {
+		if s.Required && !s.Installed {
+			missing = append(missing, s)
+		}
+	}
+	if rep.OK || len(missing) == 0 {
+		fmt.Println("The mkcert CA is already installed in every required trust store 👍")
+		fmt.Println()
+		return rep
+	}
+
+	fmt.Printf("The mkcert CA at %s will be installed into:\n\n", rep.CARoot)
+	for _, s := range missing {
+		fmt.Printf("  - %s\n", describe(s))
+	}
+	fmt.Println()
+	for _, w := range preflight(missing, rep.pre, keychain) {
+		fmt.Printf("⚠️  %s\n", w)
+	}
+	if !yes && !confirm("Continue?") {
+		fmt.Println("Nothing was installed.")
+		os.Exit(1)
+	}
+
+	// Only install into the stores asked for, and into the JDK checked:
+	// mkcert.Install leaves both to the environment.
+	var env []string
+	if stores != "" {
+		env = append(env, "TRUST_STORES", stores)
+	}
+	for _, s := range missing {
+		if s.Name == mkcert.StoreJava && os.Getenv("JAVA_HOME") == "" && rep.JavaHome != "" {
+			env = append(env, "JAVA_HOME", rep.JavaHome)
+		}
+	}
+	restore := setenv(env...)
+	_, err := mkcert.Install(opts...)
+	restore()
+	var cerr *mkcert.CertutilError
+	if err != nil && !errors.As(err, &cerr) {
+		log.Printf("mkcert -install: %v", err)
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+	}
+
+	after := check(stores, opts)
+	if !after.OK {
+		fmt.Println("Some trust stores still don't have the CA installed:")
+		fmt.Println()
+		for _, s := range after.Stores {
+			if s.Required && !s.Installed {
+				fmt.Printf("  - %s: %s\n", s.Name, nextStep(s, after.pre))
+			}
+		}
+		fmt.Println()
+	}
+	return after
+}
+
+func describe(s store) string {
+	switch s.Name {
+	case "system":
+		return "the system trust store"
+	case "nss":
+		return "Firefox/Chrome (NSS) profiles: " + strings.Join(s.Paths, ", ")
+	case "java":
+		return "the Java trust store of the JDK at " + strings.Join(s.Paths, ", ")
+	}
+	return s.Name
+}
+
+// preflight returns warnings about problems mkcert -install is likely to hit
+// for stores, given the Preflight report pre and the macOS keychain.
+func preflight(stores []store, pre *mkcert.Report, keychain string) []string {
+	var warnings []string
+	for _, s := range stores {
+		switch {
+		case !s.Found:
+			warnings = append(warnings, fmt.Sprintf("The %s trust store wasn't found (%s), so it can't be installed into.", s.Name, s.Note))
+		case s.Name == "system":
+			if w := elevation(keychain); w != "" {
+				warnings = append(warnings, w)
+			}
+		case s.Name == "nss" && !pre.Certutil:
+			warnings = append(warnings, fmt.Sprintf("certutil is needed for Firefox/Chrome but isn't installed. Install it with:\n\n\t%s\n", certutilHint(pre)))
+		}
+	}
+	return warnings
+}
+
+// elevation describes the privileges needed to write to the system store,
+// if they aren't already held.
+func elevation(keychain string) string {
+	switch runtime.GOOS {
+	case "windows":
+		return "Windows will ask you to confirm installing the CA certificate."
+	case "darwin":
+		if keychain == mkcert.KeychainLogin {
+			return "macOS will ask you to confirm trusting the CA in your login keychain."
+		}
+		return "macOS will ask for your password to update the System keychain."
+	}
+	if os.Geteuid() == 0 {
+		return ""
+	}
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return "Installing into the system store needs root, but sudo isn't available: re-run as root."
+	}
+	return "mkcert will use sudo to install into the system store, which may ask for your password."
+}
+
+// nextStep suggests what to do about a store still missing the CA, given
+// the Preflight report pre.
+func nextStep(s store, pre *mkcert.Report) string {
+	switch {
+	case !s.Found:
+		return s.Note
+	case s.Name == "system":
+		return "re-run with administrator rights, e.g. sudo catrust -install"
+	case s.Name == "nss" && !pre.Certutil:
+		return fmt.Sprintf("install certutil with %q, then re-run catrust -install", certutilHint(pre))
+	case s.Name == "nss":
+		return "close Firefox and Chrome, then re-run catrust -install"
+	case s.Name == "java":
+		return "check JAVA_HOME is the JDK your services use and that its cacerts is writable (try sudo -E catrust -install)"
+	}
+	return "re-run catrust -install"
+}
+
+// certutilHint returns the command installing certutil on this machine.
+func certutilHint(pre *mkcert.Report) string {
+	if pre.CertutilInstall == "" {
+		return "your package manager's NSS tools package"
+	}
+	if runtime.GOOS != "darwin" && os.Geteuid() != 0 {
+		return "sudo " + pre.CertutilInstall
+	}
+	return pre.CertutilInstall
+}
+
+// setenv sets the pairs of names and values in kv in the environment,
+// returning a function restoring them.
+func setenv(kv ...string) (restore func()) {
+	var undo []func()
+	for i := 0; i+1 < len(kv); i += 2 {
+		name := kv[i]
+		if old, ok := os.LookupEnv(name); ok {
+			undo = append(undo, func() { os.Setenv(name, old) })
+		} else {
+			undo = append(undo, func() { os.Unsetenv(name) })
+		}
+		os.Setenv(name, kv[i+1])
+	}
+	return func() {
+		for _, u := range undo {
+			u()
+		}
+	}
+}
+
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Println()
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+// Command catrust reports which trust stores have the mkcert root CA
+// installed. It exits with status 1 if any of the stores requested with
+// -stores is missing the CA, making it suitable as a pre-flight check.
+//
+// With -install, catrust walks through running mkcert -install, checking for
+// the tools and privileges it will need and explaining what to do about any
+// stores still missing the CA afterwards.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/icio/mkcert"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	// Flags.
+	jsonOut := flag.Bool("json", false, "print JSON")
+	stores := flag.String("stores", "", "comma-separated `stores` which must trust the CA (default those found)")
+	installFlag := flag.Bool("install", false, "install the CA in the stores missing it")
+	yes := flag.Bool("yes", false, "don't ask for confirmation with -install")
+	binary := flag.String("binary", "", "mkcert `program` to run (default found in PATH)")
+	keychain := flag.String("keychain", "", "macOS `keychain` to install the CA in: system or login (default system)")
+	flag.Parse()
+
+	// Share mkcert's report of the trust stores between the checks.
+	opts := []mkcert.Opt{mkcert.CacheTrust(new(mkcert.TrustCache))}
+	if *binary != "" {
+		opts = append(opts, mkcert.Binary(*binary))
+	}
+	if *keychain != "" {
+		opts = append(opts, mkcert.Keychain(*keychain))
+	}
+
+	rep := check(*stores, opts)
+	if *installFlag && !*jsonOut {
+		rep = install(rep, *stores, *yes, *keychain, opts)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		rep.print()
+	}
+	if !rep.OK {
+		os.Exit(1)
+	}
+}
+
+// check reports on the trust stores, marking those in the comma-separated
+// list of stores as required, or all those found when it's blank.
+func check(stores string, opts []mkcert.Opt) report {
+	trust, err := mkcert.TrustStatus(opts...)
+	if err != nil {
+		log.Println(err)
+
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+		os.Exit(1)
+	}
+
+	// TrustStatus has created the CA, so the stores have been checked.
+	pre, _ := mkcert.Preflight(context.Background(), opts...)
+	rep := report{CARoot: trust.CARoot, Stores: detect(trust, pre), JavaHome: pre.JavaHome, OK: true, pre: pre}
+	required := map[string]bool{}
+	if stores != "" {
+		for _, name := range strings.Split(stores, ",") {
+			required[strings.TrimSpace(name)] = true
+		}
+	}
+	for i, s := range rep.Stores {
+		if required[s.Name] || stores == "" && s.Found {
+			rep.Stores[i].Required = true
+			rep.OK = rep.OK && s.Installed
+		}
+		delete(required, s.Name)
+	}
+	for name := range required {
+		log.Printf("unknown trust store %q", name)
+		rep.OK = false
+	}
+	return rep
+}
+
+type report struct {
+	CARoot string  `json:"caroot"`
+	OK     bool    `json:"ok"`
+	Stores []store `json:"stores"`
+	// JavaHome is the JDK checked for the java store.
+	JavaHome string `json:"java_home,omitempty"`
+
+	pre *mkcert.Report
+}
+
+// store describes a trust store and whether it has the CA installed.
+type store struct {
+	Name      string   `json:"name"`
+	Found     bool     `json:"found"`
+	Installed bool     `json:"installed"`
+	Required  bool     `json:"required"`
+	Paths     []string `json:"paths,omitempty"`
+	Note      string   `json:"note,omitempty"`
+}
+
+// detect finds the trust stores on this machine and combines them with what
+// mkcert told us about each.
+func detect(trust mkcert.Trust, pre *mkcert.Report) []store {
+	untrusted := map[string]bool{}
+	for _, s := range trust.Untrusted {
+		untrusted[s] = true
+	}
+
+	system := store{Name: mkcert.StoreSystem, Found: true}
+	nss := store{Name: mkcert.StoreNSS, Paths: nssDatabases()}
+	nss.Found = len(nss.Paths) > 0
+	if !nss.Found {
+		nss.Note = "no Firefox or Chrome profiles found"
+	} else if !pre.Certutil {
+		// Without certutil, mkcert can't tell whether the CA is installed.
+		nss.Note = "certutil not found, mkcert can't check or install the CA"
+		untrusted[mkcert.StoreNSS] = true
+	}
+	java := store{Name: mkcert.StoreJava}
+	if pre.JavaHome == "" {
+		java.Note = "no JDK found, set JAVA_HOME"
+	} else {
+		java.Found, java.Paths = true, []string{pre.JavaHome}
+	}
+
+	enabled := map[string]bool{}
+	for _, s := range pre.Stores {
+		enabled[s.Name] = s.Enabled
+	}
+	stores := []store{system, nss, java}
+	for i, s := range stores {
+		if !enabled[s.Name] {
+			stores[i].Note = "disabled by TRUST_STORES"
+			continue
+		}
+		stores[i].Installed = s.Found && !untrusted[s.Name]
+	}
+	return stores
+}
+
+func (rep report) print() {
+	fmt.Printf("CAROOT: %s\n\n", rep.CARoot)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STORE\tINSTALLED\tREQUIRED\tDETAILS")
+	for _, s := range rep.Stores {
+		installed := "no"
+		switch {
+		case s.Installed:
+			installed = "yes"
+		case !s.Found:
+			installed = "-"
+		}
+		required := ""
+		if s.Required {
+			required = "yes"
+		}
+		details := strings.Join(append(s.Paths, s.Note), "; ")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, installed, required, strings.Trim(details, "; "))
+	}
+	w.Flush()
+	if !rep.OK {
+		fmt.Println("\nRun \"catrust -install\" to install the CA in the missing stores.")
+	}
+}
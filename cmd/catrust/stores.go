@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// nssDatabases returns the NSS certificate databases mkcert installs into:
+// Firefox profiles and the shared databases used by Chrome on Linux.
+func nssDatabases() []string {
+	home, _ := os.UserHomeDir()
+	shared := []string{
+		filepath.Join(home, ".pki", "nssdb"),
+		filepath.Join(home, "snap", "chromium", "current", ".pki", "nssdb"),
+		"/etc/pki/nssdb",
+	}
+	profiles := []string{
+		filepath.Join(home, ".mozilla", "firefox", "*"),
+		filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox", "*"),
+		filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles", "*"),
+	}
+	if appData := os.Getenv("APPDATA"); appData != "" {
+		profiles = append(profiles, filepath.Join(appData, "Mozilla", "Firefox", "Profiles", "*"))
+	}
+
+	var dbs []string
+	for _, dir := range shared {
+		if _, err := os.Stat(dir); err == nil {
+			dbs = append(dbs, dir)
+		}
+	}
+	for _, pattern := range profiles {
+		dirs, _ := filepath.Glob(pattern)
+		for _, dir := range dirs {
+			if exists(filepath.Join(dir, "cert9.db")) || exists(filepath.Join(dir, "cert8.db")) {
+				dbs = append(dbs, dir)
+			}
+		}
+	}
+	return dbs
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,82 @@
+// Command certembed generates a certificate with mkcert and writes it to a Go
+// file as PEM constants, for tests needing TLS material that's checked in. It
+// is intended to be run by go generate:
+//
+//	//go:generate go run github.com/icio/mkcert/cmd/certembed -domains localhost,127.0.0.1
+//
+// This writes certs_test.go with the constants CertPEM, KeyPEM and RootCAPEM,
+// named with -prefix if given. The certificate is only trusted where the same
+// mkcert CA is installed, and expires as certificates from mkcert do.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/icio/mkcert"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	// Flags.
+	domains := flag.String("domains", "localhost", "comma-separated `domains` to generate the certificate for")
+	out := flag.String("o", "certs_test.go", "write to `file`")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "`name` of the generated package (default $GOPACKAGE)")
+	prefix := flag.String("prefix", "", "`prefix` for the constant names")
+	flag.Parse()
+	if *pkg == "" {
+		log.Fatal("-package is required outside go generate")
+	}
+
+	cert, err := mkcert.Exec(
+		mkcert.Domains(strings.Split(*domains, ",")...),
+		mkcert.TempDir(),
+	)
+	if err != nil {
+		log.Println(err)
+
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+		cert.Cleanup()
+		os.Exit(1)
+	}
+	defer cert.Cleanup()
+
+	consts := []struct{ name, file, doc string }{
+		{"CertPEM", cert.File, "is the certificate for " + strings.Join(cert.Domains, ", ") + "."},
+		{"KeyPEM", cert.KeyFile, "is the private key of CertPEM."},
+		{"RootCAPEM", filepath.Join(cert.CARoot, "rootCA.pem"), "is the mkcert root CA which issued CertPEM."},
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by certembed %s; DO NOT EDIT.\n\n", strings.Join(os.Args[1:], " "))
+	fmt.Fprintf(&src, "package %s\n", *pkg)
+	for _, c := range consts {
+		b, err := ioutil.ReadFile(c.file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		name := *prefix + c.name
+		doc := strings.Replace(c.doc, "CertPEM", *prefix+"CertPEM", -1)
+		fmt.Fprintf(&src, "\n// %s %s\nconst %s = `%s`\n", name, doc, name, b)
+	}
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := mkcert.WriteFileAtomic(*out, formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
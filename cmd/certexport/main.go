@@ -0,0 +1,116 @@
+// Command certexport converts a certificate and key generated by mkcert into
+// the formats other TLS stacks expect.
+//
+// Usage:
+//
+//	certexport -format pkcs12 -password changeit -o cert.p12 cert.pem key.pem
+//	certexport -format tlsa -tlsa-usage 3 -tlsa-selector 1 cert.pem key.pem
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/icio/mkcert"
+	"github.com/icio/mkcert/export"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	// Flags.
+	format := flag.String("format", "", "output `format`: "+strings.Join(export.Formats, ", "))
+	out := flag.String("o", "-", "write to `file` (- for stdout)")
+	password := flag.String("password", "changeit", "keystore `password` for pkcs12 and jks")
+	alias := flag.String("alias", "mkcert", "key `alias` for jks")
+	name := flag.String("name", "", "Secret `name` for k8s (default derived from the certificate file)")
+	namespace := flag.String("namespace", "", "Secret `namespace` for k8s")
+	tlsaUsage := flag.Uint("tlsa-usage", 3, "TLSA certificate `usage` for tlsa: 0 PKIX-TA, 1 PKIX-EE, 2 DANE-TA or 3 DANE-EE")
+	tlsaSelector := flag.Uint("tlsa-selector", 1, "TLSA `selector` for tlsa: 0 whole certificate or 1 public key")
+	tlsaMatching := flag.Uint("tlsa-matching", 1, "TLSA matching `type` for tlsa: 0 full, 1 SHA-256 or 2 SHA-512")
+	caroot := flag.String("caroot", "", "mkcert CA `dir` (default from mkcert)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -format format [flags] cert.pem key.pem\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 || *format == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	certFile, keyFile := flag.Arg(0), flag.Arg(1)
+	if *tlsaUsage > 255 || *tlsaSelector > 255 || *tlsaMatching > 255 {
+		log.Fatal("-tlsa-usage, -tlsa-selector and -tlsa-matching must be under 256")
+	}
+
+	if *caroot == "" {
+		trust, err := mkcert.TrustStatus()
+		if err != nil {
+			log.Println(err)
+
+			var perr *exec.ExitError
+			if errors.As(err, &perr) {
+				log.Println("mkcert stderr:", string(perr.Stderr))
+			}
+			os.Exit(1)
+		}
+		*caroot = trust.CARoot
+	}
+
+	b, err := export.LoadFiles(certFile, keyFile, filepath.Join(*caroot, "rootCA.pem"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *format == "k8s" && *name == "" {
+		*name = secretName(certFile)
+	}
+	data, err := b.Encode(*format, export.Options{
+		Password:  *password,
+		Alias:     *alias,
+		Name:      *name,
+		Namespace: *namespace,
+
+		TLSAUsage:    uint8(*tlsaUsage),
+		TLSASelector: uint8(*tlsaSelector),
+		TLSAMatching: uint8(*tlsaMatching),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out == "-" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		perm := os.FileMode(0644)
+		if export.Private(*format) {
+			perm = 0600
+		}
+		err = mkcert.WriteFileAtomic(*out, data, perm)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// secretName derives a Kubernetes object name from the certificate's file
+// name, e.g. "localhost+2.pem" becomes "localhost-2-tls".
+func secretName(certFile string) string {
+	base := strings.TrimSuffix(filepath.Base(certFile), filepath.Ext(certFile))
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, base)
+	return strings.Trim(name, "-.") + "-tls"
+}
@@ -0,0 +1,294 @@
+// Command certinfo describes a certificate generated by mkcert: the names it
+// covers, its validity, key and fingerprints, whether it chains to the mkcert
+// root CA and whether that CA is trusted.
+//
+// Usage:
+//
+//	certinfo [-json] [-pins] cert.pem [key.pem]
+//	certinfo [-json] [-pins] [-dir dir] -domains localhost,127.0.0.1
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/icio/mkcert"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	// Flags.
+	jsonOut := flag.Bool("json", false, "print JSON")
+	domains := flag.String("domains", "", "find the certificate mkcert generates for these comma-separated `names`")
+	dir := flag.String("dir", ".", "look for the -domains certificate in `dir`")
+	pins := flag.Bool("pins", false, "also print the SHA-256 SPKI pins of the certificate and root CA, for HPKP, Android and curl")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] cert.pem [key.pem]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var certFile, keyFile string
+	switch {
+	case *domains != "" && flag.NArg() == 0:
+		certFile, keyFile = mkcert.DefaultFiles(strings.Split(*domains, ",")...)
+		certFile, keyFile = filepath.Join(*dir, certFile), filepath.Join(*dir, keyFile)
+	case *domains == "" && (flag.NArg() == 1 || flag.NArg() == 2):
+		certFile, keyFile = flag.Arg(0), flag.Arg(1)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	info, err := inspect(certFile, keyFile, *pins)
+	if err != nil {
+		log.Println(err)
+
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+		os.Exit(1)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		info.print()
+	}
+	if info.Chain != "ok" || info.KeyMatches != nil && !*info.KeyMatches {
+		os.Exit(1)
+	}
+}
+
+// certInfo is what we know of a certificate.
+type certInfo struct {
+	File         string    `json:"file"`
+	KeyFile      string    `json:"key_file,omitempty"`
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	Serial       string    `json:"serial"`
+	Names        []string  `json:"names"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Expired      bool      `json:"expired"`
+	KeyType      string    `json:"key_type"`
+	SHA256       string    `json:"sha256"`
+	SHA1         string    `json:"sha1"`
+	KeyMatches   *bool     `json:"key_matches,omitempty"`
+	CARoot       string    `json:"caroot"`
+	Chain        string    `json:"chain"`
+	Trusted      bool      `json:"trusted"`
+	TrustWarning string    `json:"trust_warning,omitempty"`
+	Pins         *pinInfo  `json:"pins,omitempty"`
+}
+
+// pinInfo is the SPKI pins of a certificate and its root CA.
+type pinInfo struct {
+	Leaf pinFormats `json:"leaf"`
+	Root pinFormats `json:"root"`
+}
+
+// pinFormats is a pin as the different pinning configs want it.
+type pinFormats struct {
+	SHA256  mkcert.Pin `json:"sha256"`
+	HPKP    string     `json:"hpkp"`
+	Android string     `json:"android"`
+	Curl    string     `json:"curl"`
+}
+
+func formatPin(p mkcert.Pin) pinFormats {
+	return pinFormats{SHA256: p, HPKP: p.HPKP(), Android: p.Android(), Curl: p.Curl()}
+}
+
+func inspect(certFile, keyFile string, pins bool) (*certInfo, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	var chain []*x509.Certificate
+	for rest := certPEM; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", certFile, err)
+		}
+		chain = append(chain, c)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", certFile)
+	}
+	leaf := chain[0]
+
+	info := &certInfo{
+		File:      certFile,
+		Subject:   leaf.Subject.String(),
+		Issuer:    leaf.Issuer.String(),
+		Serial:    fmt.Sprintf("%X", leaf.SerialNumber),
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		Expired:   time.Now().After(leaf.NotAfter),
+		KeyType:   keyType(leaf.PublicKey),
+	}
+	sum256, sum1 := sha256.Sum256(leaf.Raw), sha1.Sum(leaf.Raw)
+	info.SHA256, info.SHA1 = fingerprint(sum256[:]), fingerprint(sum1[:])
+	info.Names = append(info.Names, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.Names = append(info.Names, ip.String())
+	}
+	info.Names = append(info.Names, leaf.EmailAddresses...)
+	for _, u := range leaf.URIs {
+		info.Names = append(info.Names, u.String())
+	}
+
+	if keyFile != "" {
+		keyPEM, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tls.X509KeyPair(certPEM, keyPEM)
+		ok := err == nil
+		info.KeyFile, info.KeyMatches = keyFile, &ok
+	}
+
+	trust, err := mkcert.TrustStatus()
+	if err != nil {
+		return nil, err
+	}
+	info.CARoot, info.Trusted = trust.CARoot, trust.Trusted
+	if !trust.Trusted {
+		info.TrustWarning = `the CA isn't installed in all trust stores, run "mkcert -install"`
+	}
+	info.Chain = verify(chain, filepath.Join(trust.CARoot, "rootCA.pem"))
+	if pins {
+		leaf, root, err := mkcert.Cert{File: certFile, CARoot: trust.CARoot}.Pins()
+		if err != nil {
+			return nil, err
+		}
+		info.Pins = &pinInfo{Leaf: formatPin(leaf), Root: formatPin(root)}
+	}
+	return info, nil
+}
+
+// verify checks that chain leads to the root CA in rootFile, returning "ok" or
+// the reason it doesn't.
+func verify(chain []*x509.Certificate, rootFile string) string {
+	rootPEM, err := ioutil.ReadFile(rootFile)
+	if err != nil {
+		return err.Error()
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(rootPEM) {
+		return "no certificates in " + rootFile
+	}
+	inter := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		inter.AddCert(c)
+	}
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: inter,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+func keyType(pub interface{}) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return fmt.Sprintf("%T", pub)
+}
+
+func fingerprint(b []byte) string {
+	hex := make([]string, len(b))
+	for i, c := range b {
+		hex[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(hex, ":")
+}
+
+func (info *certInfo) print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	row := func(k, v string) { fmt.Fprintf(w, "%s:\t%s\n", k, v) }
+	row("File", info.File)
+	if info.KeyFile != "" {
+		match := "matches certificate"
+		if !*info.KeyMatches {
+			match = "DOES NOT MATCH certificate"
+		}
+		row("Key file", info.KeyFile+" ("+match+")")
+	}
+	row("Subject", info.Subject)
+	row("Issuer", info.Issuer)
+	row("Serial", info.Serial)
+	row("Names", strings.Join(info.Names, ", "))
+	row("Not before", info.NotBefore.Local().Format(time.RFC1123))
+	validity := info.NotAfter.Local().Format(time.RFC1123)
+	if info.Expired {
+		validity += " (EXPIRED)"
+	} else {
+		validity += fmt.Sprintf(" (in %d days)", int(time.Until(info.NotAfter).Hours()/24))
+	}
+	row("Not after", validity)
+	row("Key type", info.KeyType)
+	row("SHA-256", info.SHA256)
+	row("SHA-1", info.SHA1)
+	row("CAROOT", info.CARoot)
+	row("Chain", info.Chain)
+	trusted := "yes"
+	if !info.Trusted {
+		trusted = "no, " + info.TrustWarning
+	}
+	row("Trusted", trusted)
+	if info.Pins != nil {
+		for _, p := range []struct {
+			name string
+			pin  pinFormats
+		}{{"Leaf", info.Pins.Leaf}, {"Root CA", info.Pins.Root}} {
+			row(p.name+" pin", string(p.pin.SHA256))
+			row("  HPKP", p.pin.HPKP)
+			row("  Android", p.pin.Android)
+			row("  curl", p.pin.Curl)
+		}
+	}
+}
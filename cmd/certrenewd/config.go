@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// config is the JSON configuration of certrenewd, e.g.
+//
+//	{
+//	  "interval": "1h",
+//	  "renew_before": "720h",
+//	  "certs": [{
+//	    "domains": ["app.test", "*.app.test"],
+//	    "cert_file": "/srv/nginx/app.pem",
+//	    "key_file": "/srv/nginx/app-key.pem",
+//	    "command": ["docker", "kill", "-s", "HUP", "nginx"]
+//	  }, {
+//	    "domains": ["api.test"],
+//	    "cert_file": "/srv/api/cert.pem",
+//	    "key_file": "/srv/api/key.pem",
+//	    "command": ["docker", "compose", "restart", "api"]
+//	  }]
+//	}
+type config struct {
+	// Interval is how often certificates are checked. Defaults to an hour.
+	Interval duration `json:"interval"`
+	// RenewBefore is how long before expiry certificates are renewed.
+	// Defaults to 30 days.
+	RenewBefore duration `json:"renew_before"`
+	// Certs are the certificates to keep renewed.
+	Certs []certConfig `json:"certs"`
+}
+
+// certConfig is a certificate to keep renewed.
+type certConfig struct {
+	Domains  []string `json:"domains"`
+	CertFile string   `json:"cert_file"`
+	KeyFile  string   `json:"key_file"`
+	// Command is a program and its arguments run after the certificate is
+	// renewed, as by mkcert.CommandHook, with CERT_FILE, KEY_FILE and
+	// DOMAINS in its environment. The arguments are text/template templates
+	// given the mkcert.Cert, such as "{{.File}}". Shell commands can be run
+	// with ["sh", "-c", "..."].
+	Command []string `json:"command"`
+}
+
+func loadConfig(path string) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &config{
+		Interval:    duration(time.Hour),
+		RenewBefore: duration(30 * 24 * time.Hour),
+	}
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.Interval <= 0 {
+		return nil, fmt.Errorf("%s: interval must be positive", path)
+	}
+	for i, cc := range c.Certs {
+		if len(cc.Domains) == 0 || cc.CertFile == "" || cc.KeyFile == "" {
+			return nil, fmt.Errorf("%s: certs[%d]: domains, cert_file and key_file are required", path, i)
+		}
+	}
+	return c, nil
+}
+
+// duration is a time.Duration read from JSON strings like "1h30m".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New(`durations must be strings like "1h30m"`)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
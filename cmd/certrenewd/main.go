@@ -0,0 +1,143 @@
+// Command certrenewd keeps a set of mkcert certificates renewed ahead of their
+// expiry, running a command after each renewal so that the servers using them
+// can reload.
+//
+// Usage:
+//
+//	certrenewd -config certrenewd.json
+//
+// With -http, it serves /healthz, failing while certificates couldn't be
+// renewed, and /status, reporting the CA, its trust and the nearest expiry as
+// JSON, for healthchecks and dashboards:
+//
+//	certrenewd -config certrenewd.json -http 127.0.0.1:9180
+//
+// On SIGHUP, the configuration is read again and the certificates it lists
+// are checked straight away. If it can't be read, the previous configuration
+// is kept.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/icio/mkcert"
+)
+
+func main() {
+	// Flags.
+	configFile := flag.String("config", "certrenewd.json", "JSON configuration `file`")
+	once := flag.Bool("once", false, "check the certificates once and exit")
+	httpAddr := flag.String("http", "", "serve /healthz and /status on `addr`")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var st status
+	if *httpAddr != "" && !*once {
+		ln, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Serving status on http://%s/status", ln.Addr())
+		go func() { log.Fatal(http.Serve(ln, st.handler())) }()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	tick := time.NewTicker(time.Duration(cfg.Interval))
+	defer tick.Stop()
+	for {
+		var errs map[string]string
+		for _, cc := range cfg.Certs {
+			if err := renew(cc, time.Duration(cfg.RenewBefore)); err != nil {
+				log.Printf("%s: %v", cc.CertFile, err)
+				errs = withError(errs, cc.CertFile, err)
+			}
+		}
+		if *once {
+			if errs != nil {
+				os.Exit(1)
+			}
+			return
+		}
+		if *httpAddr != "" {
+			st.update(cfg.Certs, errs)
+		}
+
+		select {
+		case <-tick.C:
+		case <-hup:
+			c, err := loadConfig(*configFile)
+			if err != nil {
+				log.Printf("Reloading configuration: %v", err)
+				continue
+			}
+			log.Printf("Reloaded configuration from %s", *configFile)
+			cfg = c
+			tick.Reset(time.Duration(cfg.Interval))
+		case s := <-sig:
+			log.Printf("Received %s, exiting", s)
+			return
+		}
+	}
+}
+
+// renew reissues the certificate described by cc if it's missing, doesn't
+// match its configuration or expires within renewBefore, then runs its
+// command.
+func renew(cc certConfig, renewBefore time.Duration) error {
+	before, _ := ioutil.ReadFile(cc.CertFile)
+	cert, err := mkcert.Exec(
+		mkcert.Domains(cc.Domains...),
+		mkcert.CertFile(cc.CertFile),
+		mkcert.KeyFile(cc.KeyFile),
+		mkcert.Reuse(true),
+		mkcert.RenewBefore(renewBefore),
+		// The files are the configuration's, so are replaced when its
+		// domains change.
+		mkcert.Overwrite(true),
+	)
+	if err != nil {
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			return errors.New(err.Error() + ": " + strings.TrimSpace(string(perr.Stderr)))
+		}
+		return err
+	}
+	after, err := ioutil.ReadFile(cert.File)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(before, after) {
+		return nil
+	}
+
+	log.Printf("%s: issued for %s", cert.File, strings.Join(cert.Domains, ", "))
+	if len(cc.Command) > 0 {
+		if err := mkcert.CommandHook(cc.Command[0], cc.Command[1:]...)(cert); err != nil {
+			var perr *exec.ExitError
+			if errors.As(err, &perr) && len(perr.Stderr) > 0 {
+				log.Printf("%s: command output: %s", cert.File, bytes.TrimSpace(perr.Stderr))
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/icio/mkcert"
+)
+
+// status is what certrenewd found at its last check, served by -http.
+type status struct {
+	mu sync.Mutex
+	// CARoot and CAFingerprint identify the CA, the fingerprint being the
+	// hex SHA-256 of its certificate.
+	CARoot        string `json:"caroot"`
+	CAFingerprint string `json:"ca_fingerprint"`
+	// Trusted and Untrusted are the trust of the CA, as for mkcert.Trust.
+	Trusted   bool     `json:"trusted"`
+	Untrusted []string `json:"untrusted,omitempty"`
+	// Certs is the number of configured certificates which have been
+	// issued, and Expiry is when the first of them to expire does so.
+	Certs      int       `json:"certs"`
+	Expiry     time.Time `json:"nearest_expiry,omitzero"`
+	ExpiryFile string    `json:"nearest_expiry_file,omitempty"`
+	// Checked is when the certificates were last checked, and Errors are
+	// those the check failed with, by certificate file.
+	Checked time.Time         `json:"checked,omitzero"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// update records the check of certs, which failed with errs.
+func (s *status) update(certs []certConfig, errs map[string]string) {
+	u := status{Checked: time.Now(), Errors: errs}
+	if t, err := mkcert.TrustStatus(); err != nil {
+		u.Errors = withError(u.Errors, "caroot", err)
+	} else {
+		u.CARoot, u.Trusted, u.Untrusted = t.CARoot, t.Trusted, t.Untrusted
+		if root, err := (&mkcert.CA{Dir: t.CARoot}).Root(); err != nil {
+			u.Errors = withError(u.Errors, "caroot", err)
+		} else {
+			sum := sha256.Sum256(root.Raw)
+			u.CAFingerprint = hex.EncodeToString(sum[:])
+		}
+	}
+	for _, cc := range certs {
+		leaf, err := readLeaf(cc.CertFile)
+		if err != nil {
+			continue
+		}
+		u.Certs++
+		if u.Expiry.IsZero() || leaf.NotAfter.Before(u.Expiry) {
+			u.Expiry, u.ExpiryFile = leaf.NotAfter, cc.CertFile
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CARoot, s.CAFingerprint = u.CARoot, u.CAFingerprint
+	s.Trusted, s.Untrusted = u.Trusted, u.Untrusted
+	s.Certs, s.Expiry, s.ExpiryFile = u.Certs, u.Expiry, u.ExpiryFile
+	s.Checked, s.Errors = u.Checked, u.Errors
+}
+
+// healthy reports whether the last check succeeded.
+func (s *status) healthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.Checked.IsZero() && len(s.Errors) == 0
+}
+
+// handler serves /healthz, which is 200 OK while the last check succeeded
+// and 503 otherwise, for healthchecks, and /status, the status as JSON.
+func (s *status) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.healthy() {
+			http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		b, err := json.MarshalIndent(s, "", "  ")
+		s.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(b, '\n'))
+	})
+	return mux
+}
+
+func withError(errs map[string]string, key string, err error) map[string]string {
+	if errs == nil {
+		errs = make(map[string]string)
+	}
+	errs[key] = err.Error()
+	return errs
+}
+
+// readLeaf parses the first certificate in the PEM file at path.
+func readLeaf(path string) (*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("no certificate in " + path)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const echoService = "echo.Echo"
+
+// echoFile describes the echo service, as the descriptor protoc would
+// generate from:
+//
+//	syntax = "proto3";
+//	package echo;
+//	import "google/protobuf/wrappers.proto";
+//	service Echo {
+//	  rpc Echo(google.protobuf.StringValue) returns (google.protobuf.StringValue);
+//	  rpc EchoStream(stream google.protobuf.StringValue) returns (stream google.protobuf.StringValue);
+//	}
+//
+// It's registered so that the reflection service can describe the service.
+var echoFile = &descriptorpb.FileDescriptorProto{
+	Name:       proto.String("echo.proto"),
+	Package:    proto.String("echo"),
+	Dependency: []string{"google/protobuf/wrappers.proto"},
+	Syntax:     proto.String("proto3"),
+	Service: []*descriptorpb.ServiceDescriptorProto{{
+		Name: proto.String("Echo"),
+		Method: []*descriptorpb.MethodDescriptorProto{{
+			Name:       proto.String("Echo"),
+			InputType:  proto.String(".google.protobuf.StringValue"),
+			OutputType: proto.String(".google.protobuf.StringValue"),
+		}, {
+			Name:            proto.String("EchoStream"),
+			InputType:       proto.String(".google.protobuf.StringValue"),
+			OutputType:      proto.String(".google.protobuf.StringValue"),
+			ClientStreaming: proto.Bool(true),
+			ServerStreaming: proto.Bool(true),
+		}},
+	}},
+}
+
+// registerEcho registers the echo service and its descriptor with srv.
+func registerEcho(srv *grpc.Server) error {
+	fd, err := protodesc.NewFile(echoFile, protoregistry.GlobalFiles)
+	if err != nil {
+		return err
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		return err
+	}
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: echoService,
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Echo",
+			Handler:    echoHandler,
+		}},
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "EchoStream",
+			Handler:       echoStreamHandler,
+			ClientStreams: true,
+			ServerStreams: true,
+		}},
+		Metadata: echoFile.GetName(),
+	}, struct{}{})
+	return nil
+}
+
+// echoHandler returns the message it's sent.
+func echoHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	echo := func(ctx context.Context, req interface{}) (interface{}, error) {
+		logCall(ctx, "Echo")
+		return req, nil
+	}
+	if interceptor == nil {
+		return echo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + echoService + "/Echo"}
+	return interceptor(ctx, in, info, echo)
+}
+
+// echoStreamHandler returns each message it's sent until the client closes
+// the stream.
+func echoStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	logCall(stream.Context(), "EchoStream")
+	for {
+		in := new(wrapperspb.StringValue)
+		if err := stream.RecvMsg(in); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(in); err != nil {
+			return err
+		}
+	}
+}
+
+func logCall(ctx context.Context, method string) {
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	log.Printf("%s: %s", addr, method)
+}
@@ -0,0 +1,99 @@
+// Command grpcecho serves a gRPC echo service, with the standard health and
+// reflection services, over TLS using a certificate from mkcert, as a trusted
+// target for grpcurl and gRPC clients:
+//
+//	grpcurl localhost:12346 list
+//	grpcurl -d '"hello"' localhost:12346 echo.Echo/Echo
+//	grpcurl localhost:12346 grpc.health.v1.Health/Check
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/icio/mkcert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+func main() {
+	// Flags.
+	bind := flag.String("b", "localhost:12346", "bind host:addr")
+	flag.Parse()
+
+	// Get our certificate.
+	cert, err := mkcert.Exec(
+		mkcert.Domains("localhost"),
+		mkcert.RequireTrusted(true),
+		// TempDir keeps the certificate files in a temporary directory,
+		// removed by cert.Cleanup.
+		mkcert.TempDir(),
+	)
+	if err != nil {
+		log.Println(err)
+
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+		cert.Cleanup()
+		os.Exit(1)
+	}
+	defer cert.Cleanup()
+
+	pair, err := tls.LoadX509KeyPair(cert.File, cert.KeyFile)
+	if err != nil {
+		log.Println(err)
+		cert.Cleanup()
+		os.Exit(1)
+	}
+	ln, err := net.Listen("tcp", *bind)
+	if err != nil {
+		log.Println(err)
+		cert.Cleanup()
+		os.Exit(1)
+	}
+
+	log.Printf("Using certificate: %#v", cert)
+	log.Printf("✨ grpcurl %s list ✨", *bind)
+
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pair},
+	})))
+	if err := registerEcho(srv); err != nil {
+		log.Println(err)
+		cert.Cleanup()
+		os.Exit(1)
+	}
+	hs := health.NewServer()
+	hs.SetServingStatus(echoService, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	// Serve until interrupted, then shut down gracefully.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-errc:
+	case s := <-sig:
+		log.Printf("Received %s, shutting down", s)
+		hs.Shutdown()
+		srv.GracefulStop()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
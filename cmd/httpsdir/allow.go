@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientFilter decides which client IPs are served, from the -allow and -deny
+// flags. Denied networks take priority. If any networks are allowed, other
+// clients are refused, except for local ones which are always allowed unless
+// denied.
+type clientFilter struct {
+	allow, deny netFlags
+}
+
+func (f *clientFilter) active() bool {
+	return len(f.allow) > 0 || len(f.deny) > 0
+}
+
+// allowed reports whether the client at addr, a host:port, is served.
+func (f *clientFilter) allowed(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if f.deny.contains(ip) {
+		return false
+	}
+	return len(f.allow) == 0 || ip.IsLoopback() || f.allow.contains(ip)
+}
+
+// listener wraps ln to close the connections of refused clients before
+// anything is read from them, such as the TLS handshake.
+func (f *clientFilter) listener(ln net.Listener) net.Listener {
+	return &filterListener{ln, f}
+}
+
+// handler wraps h to refuse requests from refused clients with 403s, for the
+// connections which aren't accepted through listener, such as HTTP/3.
+func (f *clientFilter) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.allowed(r.RemoteAddr) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+type filterListener struct {
+	net.Listener
+	f *clientFilter
+}
+
+func (l *filterListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil || l.f.allowed(c.RemoteAddr().String()) {
+			return c, err
+		}
+		c.Close()
+	}
+}
+
+// netFlags collects the repeatable -allow and -deny flags, of networks in
+// CIDR notation or single IP addresses.
+type netFlags []*net.IPNet
+
+func (n *netFlags) String() string {
+	var s []string
+	for _, ipnet := range *n {
+		s = append(s, ipnet.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (n *netFlags) Set(v string) error {
+	if !strings.Contains(v, "/") {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return fmt.Errorf("expected an IP address or CIDR network, got %q", v)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		*n = append(*n, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		return nil
+	}
+	_, ipnet, err := net.ParseCIDR(v)
+	if err != nil {
+		return fmt.Errorf("expected an IP address or CIDR network, got %q", v)
+	}
+	*n = append(*n, ipnet)
+	return nil
+}
+
+func (n netFlags) contains(ip net.IP) bool {
+	for _, ipnet := range n {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
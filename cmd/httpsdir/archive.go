@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// archiveDir wraps h, serving fs, to download directories as archives when
+// requested with ?archive=zip or ?archive=tar.gz. Archives are streamed as
+// they're written, and include the regular files and directories beneath
+// the requested one.
+func archiveDir(h http.Handler, fs http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("archive")
+		if format == "" || r.Method != http.MethodGet {
+			h.ServeHTTP(w, r)
+			return
+		}
+		name := path.Clean("/" + r.URL.Path)
+		d, err := fs.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		fi, err := d.Stat()
+		d.Close()
+		if err != nil || !fi.IsDir() {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		var a archiver
+		switch format {
+		case "zip":
+			w.Header().Set("Content-Type", "application/zip")
+			a = zipArchiver{zip.NewWriter(w)}
+		case "tar.gz", "tgz":
+			format = "tar.gz"
+			w.Header().Set("Content-Type", "application/gzip")
+			gw := gzip.NewWriter(w)
+			a = tarArchiver{tar.NewWriter(gw), gw}
+		default:
+			http.Error(w, "archive must be zip or tar.gz", http.StatusBadRequest)
+			return
+		}
+		base := path.Base(name)
+		if base == "/" {
+			base = "root"
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="`+strings.Replace(base, `"`, "", -1)+"."+format+`"`)
+
+		// Once streaming has begun, a failure can only cut the archive short.
+		if err := archiveFiles(a, fs, name, base); err != nil {
+			log.Printf("archiving %s: %v", name, err)
+			return
+		}
+		if err := a.Close(); err != nil {
+			log.Printf("archiving %s: %v", name, err)
+		}
+	})
+}
+
+// archiveFiles adds dir in fs, and the regular files and directories beneath
+// it, to a under the name prefix.
+func archiveFiles(a archiver, fs http.FileSystem, dir, prefix string) error {
+	d, err := fs.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	fi, err := d.Stat()
+	if err != nil {
+		return err
+	}
+	if err := a.add(prefix+"/", fi, nil); err != nil {
+		return err
+	}
+	infos, err := d.Readdir(-1)
+	if err != nil {
+		return err
+	}
+	for _, fi := range infos {
+		name, rel := path.Join(dir, fi.Name()), prefix+"/"+fi.Name()
+		switch {
+		case fi.IsDir():
+			err = archiveFiles(a, fs, name, rel)
+		case fi.Mode().IsRegular():
+			var f http.File
+			if f, err = fs.Open(name); err == nil {
+				err = a.add(rel, fi, f)
+				f.Close()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiver writes an archive, adding directories with a nil r.
+type archiver interface {
+	add(name string, fi os.FileInfo, r io.Reader) error
+	Close() error
+}
+
+type zipArchiver struct{ *zip.Writer }
+
+func (a zipArchiver) add(name string, fi os.FileInfo, r io.Reader) error {
+	hdr, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if r != nil {
+		hdr.Method = zip.Deflate
+	}
+	f, err := a.CreateHeader(hdr)
+	if err != nil || r == nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
+
+type tarArchiver struct {
+	*tar.Writer
+	gz *gzip.Writer
+}
+
+func (a tarArchiver) add(name string, fi os.FileInfo, r io.Reader) error {
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := a.WriteHeader(hdr); err != nil || r == nil {
+		return err
+	}
+	_, err = io.Copy(a.Writer, r)
+	return err
+}
+
+func (a tarArchiver) Close() error {
+	if err := a.Writer.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// daemonEnv is set in the environment of the background process started by
+// -daemon.
+const daemonEnv = "HTTPSDIR_DAEMON"
+
+// daemonized reports whether this is the background process of -daemon.
+func daemonized() bool {
+	return os.Getenv(daemonEnv) == "1"
+}
+
+// startDaemon re-runs httpsdir in the background, detached from the terminal,
+// with its output appended to logFile. The new process records its pid in
+// pidFile.
+func startDaemon(pidFile, logFile string) error {
+	if pid, err := readPid(pidFile); err == nil && alive(pid) {
+		return fmt.Errorf("already running as pid %d (see %s)", pid, pidFile)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var args []string
+	for _, a := range os.Args[1:] {
+		switch strings.TrimLeft(a, "-") {
+		case "daemon", "daemon=true":
+			continue
+		}
+		args = append(args, a)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.SysProcAttr = detached()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	fmt.Printf("httpsdir running as pid %d, logging to %s\n", cmd.Process.Pid, logFile)
+	return cmd.Process.Release()
+}
+
+// stopDaemon asks the process recorded in pidFile to shut down, and waits
+// for it to do so.
+func stopDaemon(pidFile string) error {
+	pid, err := readPid(pidFile)
+	if err != nil {
+		return err
+	}
+	if !alive(pid) {
+		os.Remove(pidFile)
+		return fmt.Errorf("pid %d from %s isn't running", pid, pidFile)
+	}
+	if err := terminate(pid); err != nil {
+		return err
+	}
+	for i := 0; i < 100 && alive(pid); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if alive(pid) {
+		return fmt.Errorf("pid %d didn't stop", pid)
+	}
+	fmt.Printf("Stopped httpsdir pid %d\n", pid)
+	return nil
+}
+
+func writePid(pidFile string) error {
+	return ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+func readPid(pidFile string) (int, error) {
+	b, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, errors.New("invalid pidfile " + pidFile)
+	}
+	return pid, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	createNewProcessGroup = 0x00000200
+	detachedProcess       = 0x00000008
+)
+
+// detached starts the process without a console, so that it outlives the
+// one it was started from.
+func detached() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: createNewProcessGroup | detachedProcess}
+}
+
+func alive(pid int) bool {
+	// FindProcess opens a handle to the process, failing if it has exited.
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// terminate stops pid. Windows has no equivalent of SIGTERM for a process
+// without a console, so it doesn't get to shut down gracefully.
+func terminate(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
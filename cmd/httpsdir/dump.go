@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dumper logs each request's headers and its response's status and headers,
+// with up to maxBody bytes of the bodies.
+type dumper struct {
+	log     *log.Logger
+	maxBody int
+	seq     atomic.Int64
+}
+
+// handler wraps h to dump its requests and responses.
+func (d *dumper) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := d.seq.Add(1)
+		start := time.Now()
+		head, err := httputil.DumpRequest(r, false)
+		if err != nil {
+			d.log.Printf("#%d: %v", id, err)
+		}
+		var body string
+		if d.maxBody > 0 && r.Body != nil && r.Body != http.NoBody {
+			// Read the start of the body for the dump, and put it back.
+			buf := make([]byte, d.maxBody)
+			n, _ := io.ReadFull(r.Body, buf)
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(buf[:n]), r.Body), r.Body}
+			c := capture{max: d.maxBody}
+			c.Write(buf[:n])
+			if r.ContentLength > c.n || (r.ContentLength < 0 && n == d.maxBody) {
+				c.n = r.ContentLength
+			}
+			body = fmt.Sprintf("\n#%d request body: %s", id, c.String())
+		}
+		d.log.Printf("#%d request from %s\n%s%s", id, r.RemoteAddr, bytes.TrimRight(head, "\r\n"), body)
+
+		dw := &dumpWriter{ResponseWriter: w, body: capture{max: d.maxBody}}
+		h.ServeHTTP(dw, r)
+
+		var b strings.Builder
+		status := dw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		fmt.Fprintf(&b, "#%d response %d %s in %s\n", id, status, http.StatusText(status), time.Since(start).Round(time.Microsecond))
+		writeHeader(&b, w.Header())
+		if dw.body.n > 0 {
+			fmt.Fprintf(&b, "#%d response body: %s\n", id, dw.body.String())
+		}
+		d.log.Print(strings.TrimRight(b.String(), "\n"))
+	})
+}
+
+func writeHeader(w io.Writer, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(w, "%s: %s\n", k, v)
+		}
+	}
+}
+
+// capture keeps up to max bytes of a body, counting the rest.
+type capture struct {
+	max int
+	buf bytes.Buffer
+	n   int64
+}
+
+func (c *capture) Write(b []byte) {
+	c.n += int64(len(b))
+	if room := c.max - c.buf.Len(); room > 0 {
+		if len(b) > room {
+			b = b[:room]
+		}
+		c.buf.Write(b)
+	}
+}
+
+// String quotes the captured bytes, noting the total size if they're only
+// the start of the body, or if it's unknown (-1).
+func (c *capture) String() string {
+	switch {
+	case c.n < 0:
+		return fmt.Sprintf("%q... (of unknown length)", c.buf.Bytes())
+	case int64(c.buf.Len()) < c.n:
+		return fmt.Sprintf("%q... (%d bytes)", c.buf.Bytes(), c.n)
+	}
+	return fmt.Sprintf("%q", c.buf.Bytes())
+}
+
+// dumpWriter records the status and body of a response.
+type dumpWriter struct {
+	http.ResponseWriter
+	status int
+	body   capture
+}
+
+func (w *dumpWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *dumpWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body.max > 0 {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *dumpWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSockets, such as -livereload's, take over the connection.
+func (w *dumpWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *dumpWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
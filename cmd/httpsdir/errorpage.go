@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errorTemplate is the page served for errors without an -error-page.
+var errorTemplate = template.Must(template.New("error").Parse(`<!doctype html>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width">
+<title>{{.Status}} {{.Text}}</title>
+<style>
+body { font: 16px/1.5 system-ui, sans-serif; color: #333; max-width: 40em; margin: 15vh auto; padding: 0 1em; }
+h1 { font-weight: 500; }
+h1 span { color: #999; }
+code { background: #f3f3f3; padding: .1em .3em; border-radius: 3px; }
+</style>
+<h1><span>{{.Status}}</span> {{.Text}}</h1>
+<p><code>{{.Path}}</code></p>
+`))
+
+// withErrorPages wraps h to replace the bodies of its error responses with the
+// file configured for their status in pages, or else errorTemplate. The files
+// are read for each error, so they can be edited while serving.
+func withErrorPages(h http.Handler, pages errorPageFlags) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&errorWriter{ResponseWriter: w, pages: pages, path: requestPath(r)}, r)
+	})
+}
+
+// errorWriter swaps the body of error responses for an error page.
+type errorWriter struct {
+	http.ResponseWriter
+	pages       errorPageFlags
+	path        string
+	wroteHeader bool
+	replaced    bool
+}
+
+func (w *errorWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status < 400 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.replaced = true
+	hdr := w.Header()
+	hdr.Del("Content-Length")
+	hdr.Del("Content-Encoding")
+	hdr.Set("Content-Type", "text/html; charset=utf-8")
+	if file, ok := w.pages[status]; ok {
+		if page, err := ioutil.ReadFile(file); err == nil {
+			w.ResponseWriter.WriteHeader(status)
+			w.ResponseWriter.Write(page)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+	errorTemplate.Execute(w.ResponseWriter, struct {
+		Status     int
+		Text, Path string
+	}{status, http.StatusText(status), w.path})
+}
+
+func (w *errorWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.replaced {
+		// Drop the original body.
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.replaced {
+		f.Flush()
+	}
+}
+
+// errorPageFlags collects the repeatable -error-page flag.
+type errorPageFlags map[int]string
+
+func (e *errorPageFlags) String() string {
+	var s []string
+	for status, file := range *e {
+		s = append(s, strconv.Itoa(status)+"="+file)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (e *errorPageFlags) Set(v string) error {
+	eq := strings.Index(v, "=")
+	if eq < 1 || eq == len(v)-1 {
+		return fmt.Errorf("expected status=file, got %q", v)
+	}
+	status, err := strconv.Atoi(v[:eq])
+	if err != nil || status < 400 || status > 599 {
+		return fmt.Errorf("expected an error status from 400 to 599, got %q", v[:eq])
+	}
+	if *e == nil {
+		*e = make(errorPageFlags)
+	}
+	(*e)[status] = v[eq+1:]
+	return nil
+}
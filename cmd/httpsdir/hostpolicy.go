@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// localSuffixes are the domains reserved for local and testing use, whose
+// names can't belong to anyone else.
+var localSuffixes = []string{".localhost", ".test", ".local"}
+
+// localHostPolicy allows certificates to be issued on demand only for names
+// which can't be anyone else's: those under localSuffixes, and those, such as
+// /etc/hosts aliases, resolving only to this machine. Otherwise any client
+// which can reach the server could have the trusted root CA sign a
+// certificate for someone else's domain.
+func localHostPolicy(ctx context.Context, host string) error {
+	if host == "localhost" {
+		return nil
+	}
+	for _, s := range localSuffixes {
+		if strings.HasSuffix(host, s) {
+			return nil
+		}
+	}
+	local, err := localAddrs()
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !local[ip.String()] {
+			return fmt.Errorf("%s isn't an address of this machine", host)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if !local[a.IP.String()] {
+			return fmt.Errorf("%s resolves to %s, which isn't this machine", host, a.IP)
+		}
+	}
+	return nil
+}
+
+// localAddrs returns the addresses of this machine's interfaces, as strings.
+func localAddrs() (map[string]bool, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	local := map[string]bool{"127.0.0.1": true, "::1": true}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok {
+			local[n.IP.String()] = true
+		}
+	}
+	return local, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"time"
+)
+
+// indexEntry describes a file in a JSON directory listing.
+type indexEntry struct {
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256,omitempty"`
+}
+
+// jsonIndex wraps h, serving fs, to list directories as JSON when requested
+// with ?format=json: the name, size, modification time and, for regular
+// files, SHA-256 of each entry.
+func jsonIndex(h http.Handler, fs http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "json" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		name := path.Clean("/" + r.URL.Path)
+		d, err := fs.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer d.Close()
+		if fi, err := d.Stat(); err != nil || !fi.IsDir() {
+			h.ServeHTTP(w, r)
+			return
+		}
+		infos, err := d.Readdir(-1)
+		if err != nil {
+			http.Error(w, "error reading directory", http.StatusInternalServerError)
+			return
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+		entries := make([]indexEntry, 0, len(infos))
+		for _, fi := range infos {
+			e := indexEntry{Name: fi.Name(), Dir: fi.IsDir(), Size: fi.Size(), ModTime: fi.ModTime().UTC()}
+			if fi.Mode().IsRegular() {
+				e.SHA256 = fileSum(fs, path.Join(name, fi.Name()))
+			}
+			entries = append(entries, e)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			Path    string       `json:"path"`
+			Entries []indexEntry `json:"entries"`
+		}{requestPath(r), entries})
+	})
+}
+
+// fileSum returns the hex SHA-256 of the file name in fs, or "" if it can't
+// be read.
+func fileSum(fs http.FileSystem, name string) string {
+	f, err := fs.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestPath returns the path the client asked for, before any mount's
+// prefix was stripped.
+func requestPath(r *http.Request) string {
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		return u.Path
+	}
+	return r.URL.Path
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation. See sd_listen_fds(3).
+const listenFdsStart = 3
+
+// listen returns the socket inherited through systemd socket activation when
+// one was passed to this process, and otherwise listens on addr. The returned
+// bool reports whether the listener was inherited.
+func listen(addr string) (net.Listener, bool, error) {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	fds, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if pid != os.Getpid() || fds < 1 {
+		ln, err := net.Listen("tcp", addr)
+		return ln, false, err
+	}
+
+	// Don't leak the activation environment to children.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if fds > 1 {
+		return nil, false, fmt.Errorf("socket activation: expected 1 socket, got %d", fds)
+	}
+	f := os.NewFile(listenFdsStart, "LISTEN_FD_3")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, false, fmt.Errorf("socket activation: %w", err)
+	}
+	return ln, true, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+)
+
+// livereloadPath is the WebSocket endpoint pages subscribe to.
+const livereloadPath = "/.livereload"
+
+// livereloadScript is injected into HTML responses and reloads the page once
+// the server reports a change.
+const livereloadScript = `<script>new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "` + livereloadPath + `").onmessage = function() { location.reload(); };</script>`
+
+// livereloadDelay is how long after a change the pages are reloaded, so that
+// a burst of changes, such as a build writing many files, reloads them once.
+const livereloadDelay = 100 * time.Millisecond
+
+// reloader notifies subscribed pages whenever the watched directories change.
+type reloader struct {
+	mu       sync.Mutex
+	changed  chan struct{}
+	upgrader websocket.Upgrader
+}
+
+func newReloader() *reloader {
+	return &reloader{changed: make(chan struct{})}
+}
+
+func (r *reloader) wait() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.changed
+}
+
+func (r *reloader) notify() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// watch watches dirs and the directories beneath them with fsnotify,
+// notifying once changes settle for livereloadDelay. Directories created
+// later are watched as they appear. It returns if the watcher can't be
+// started, or fails.
+func (r *reloader) watch(dirs ...string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for _, dir := range dirs {
+		if err := watchTree(w, dir); err != nil {
+			return err
+		}
+	}
+
+	var settle <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					watchTree(w, ev.Name)
+				}
+			}
+			settle = time.After(livereloadDelay)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-settle:
+			settle = nil
+			r.notify()
+		}
+	}
+}
+
+// watchTree adds dir and the directories beneath it to w.
+func watchTree(w *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		return w.Add(path)
+	})
+}
+
+// ServeHTTP upgrades the request to a WebSocket, and sends a message on it
+// each time a change is seen.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	conn, err := r.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// The upgrader has already replied.
+		return
+	}
+	defer conn.Close()
+
+	// Read until the page goes away, so that we stop writing to it.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-r.wait():
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("reload")); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// injectLivereload wraps h to add livereloadScript to its HTML responses.
+func injectLivereload(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Always serve HTML in full so that we can rewrite it.
+		r.Header.Del("Range")
+		r.Header.Del("If-Modified-Since")
+		r.Header.Del("If-None-Match")
+
+		iw := &injectWriter{ResponseWriter: w}
+		h.ServeHTTP(iw, r)
+		iw.finish()
+	})
+}
+
+// injectWriter buffers successful HTML responses so that livereloadScript can
+// be inserted ahead of the closing body tag. Other responses pass through.
+type injectWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	buf         *bytes.Buffer
+}
+
+func (w *injectWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		w.buf = new(bytes.Buffer)
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *injectWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buf != nil {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *injectWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && w.buf == nil {
+		f.Flush()
+	}
+}
+
+func (w *injectWriter) finish() {
+	if w.buf == nil {
+		return
+	}
+	body := w.buf.Bytes()
+	if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+		body = append(body[:i:i], append([]byte(livereloadScript), body[i:]...)...)
+	} else {
+		body = append(body, livereloadScript...)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/icio/mkcert"
+)
+
+// jsonLog is the logger for -log-format json, or nil for plain text.
+var jsonLog *slog.Logger
+
+// quiet drops the informational messages logged with infof, for -quiet.
+var quiet bool
+
+// setupLog configures logging for -log-format and -quiet. With json, each
+// message is written to stderr as a JSON object with time, level and msg
+// fields. Messages logged with infof are at level INFO, those beginning
+// "Warning: " at WARN, and everything else logged through the log package,
+// which is errors, at ERROR.
+func setupLog(format string, q bool) error {
+	quiet = q
+	switch format {
+	case "text":
+		return nil
+	case "json":
+		level := slog.LevelInfo
+		if quiet {
+			level = slog.LevelWarn
+		}
+		h := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+		jsonLog = slog.New(h)
+		log.SetFlags(0)
+		log.SetOutput(logWriter{h})
+		return nil
+	}
+	return fmt.Errorf("unknown -log-format %q, want text or json", format)
+}
+
+// infof logs an informational message, such as what's being served, unless
+// -quiet.
+func infof(format string, v ...interface{}) {
+	switch {
+	case quiet:
+	case jsonLog != nil:
+		jsonLog.Info(fmt.Sprintf(format, v...))
+	default:
+		log.Printf(format, v...)
+	}
+}
+
+// started logs the URL being served, and the certificate files, even with
+// -quiet. With -log-format json, they're fields of a "serving" event, for
+// supervisors and scripts waiting for the server to come up.
+func started(url string, cert mkcert.Cert, leaf *x509.Certificate) {
+	if jsonLog == nil {
+		if !quiet {
+			log.Printf("Using certificate for %s, expiring %s", strings.Join(leaf.DNSNames, ", "), leaf.NotAfter.Format(time.RFC3339))
+		}
+		log.Printf("✨ %s ✨", url)
+		return
+	}
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "serving", 0)
+	r.AddAttrs(
+		slog.String("url", url),
+		slog.Any("domains", leaf.DNSNames),
+		slog.Time("expires", leaf.NotAfter),
+		slog.String("cert_file", cert.File),
+		slog.String("key_file", cert.KeyFile),
+		slog.String("caroot", cert.CARoot),
+	)
+	jsonLog.Handler().Handle(context.Background(), r)
+}
+
+// logWriter turns the log package's messages into records for h.
+type logWriter struct {
+	h slog.Handler
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	level := slog.LevelError
+	if strings.HasPrefix(msg, "Warning: ") {
+		level, msg = slog.LevelWarn, strings.TrimPrefix(msg, "Warning: ")
+	}
+	ctx := context.Background()
+	if w.h.Enabled(ctx, level) {
+		if err := w.h.Handle(ctx, slog.NewRecord(time.Now(), level, msg, 0)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
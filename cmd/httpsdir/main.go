@@ -1,41 +1,175 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/icio/mkcert"
+	"github.com/quic-go/quic-go/http3"
 )
 
 func main() {
 	// Flags.
 	bind := flag.String("b", "localhost:12345", "bind host:addr")
+	var mounts mountFlags
+	flag.Var(&mounts, "mount", "serve `prefix=dir` under a URL prefix (repeatable, default /=.)")
+	flag.Var(routeFlags{&mounts}, "route", "serve `prefix=static:dir` or proxy prefix=proxy:url under a URL prefix (repeatable)")
+	var errorPages errorPageFlags
+	flag.Var(&errorPages, "error-page", "serve `status=file`, such as 404=./404.html, for errors with the status (repeatable)")
+	var clients clientFilter
+	flag.Var(&clients.allow, "allow", "only serve clients in `network`, such as 192.168.1.0/24, and local ones (repeatable)")
+	flag.Var(&clients.deny, "deny", "refuse clients in `network`, even if allowed (repeatable)")
+	var vhosts vhostFlags
+	flag.Var(&vhosts, "vhost", "serve `host=dir` to requests for host, which the certificate also covers (repeatable)")
+	livereload := flag.Bool("livereload", false, "reload HTML pages when served files change")
+	dav := flag.Bool("webdav", false, "allow the served directories to be mounted and modified over WebDAV")
+	h3 := flag.Bool("h3", false, "also serve HTTP/3 over QUIC on the same port")
+	http1Only := flag.Bool("http1-only", false, "only serve HTTP/1.1 over TCP, refusing to negotiate HTTP/2")
+	h2Only := flag.Bool("h2", false, "only serve HTTP/2 over TCP, refusing clients which can't negotiate it")
+	mdns := flag.String("mdns", "", "advertise the server over mDNS as `name`.local")
+	rateLimit := flag.Float64("rate", 0, "limit each client to `N` requests per second (0 for unlimited)")
+	burst := flag.Int("burst", 0, "allow each client bursts of `M` requests over -rate (default -rate)")
+	maxBody := flag.Int64("max-body", 0, "refuse request bodies over `bytes` with 413s (0 for unlimited)")
+	maxHeader := flag.Int("max-header", 0, "limit request headers to `bytes` (default 1MB)")
+	var headers headerFlags
+	flag.Var(&headers, "header", "add `\"Name: value\"` to every response (repeatable)")
+	hsts := flag.Duration("hsts", 0, "send Strict-Transport-Security with max-age `duration`; browsers will then refuse plain HTTP to localhost on any port")
+	secureHeaders := flag.Bool("secure-headers", false, "send the security headers of a production site, such as X-Content-Type-Options and Referrer-Policy, unless set by -header")
+	certDir := flag.String("cert-dir", "", "generate and reuse certificates in `dir` (default a new temporary directory)")
+	daemon := flag.Bool("daemon", false, "run in the background, detached from the terminal")
+	pidFile := flag.String("pidfile", filepath.Join(os.TempDir(), "httpsdir.pid"), "record the -daemon process id in `file`")
+	logFile := flag.String("logfile", filepath.Join(os.TempDir(), "httpsdir.log"), "append -daemon output to `file`")
+	stop := flag.Bool("stop", false, "stop the -daemon process recorded in -pidfile")
+	dump := flag.Bool("dump", false, "log the headers of every request and response")
+	dumpBody := flag.Int("dump-body", 0, "with -dump, also log up to `bytes` of each request and response body")
+	dumpFile := flag.String("dump-file", "", "with -dump, log to `file` instead of stderr")
+	logFormat := flag.String("log-format", "text", "log as `text` or json, one object per line")
+	flag.BoolVar(&quiet, "quiet", false, "only log the URL being served, warnings and errors")
+	keyLog := flag.String("keylog", os.Getenv("SSLKEYLOGFILE"), "append TLS secrets to `file` for decrypting traffic, e.g. in Wireshark (default $SSLKEYLOGFILE)")
 	flag.Parse()
+	if err := setupLog(*logFormat, quiet); err != nil {
+		log.Fatal(err)
+	}
+
+	if *http1Only && *h2Only {
+		log.Fatal("-http1-only and -h2 can't be used together")
+	}
+	if _, ok := headers["Strict-Transport-Security"]; ok && *hsts > 0 {
+		log.Fatal("-hsts and -header Strict-Transport-Security can't be used together")
+	}
+	if *livereload && *h2Only {
+		log.Printf("Warning: -livereload's WebSocket needs HTTP/1.1, which -h2 refuses")
+	}
+
+	switch {
+	case *stop:
+		if err := stopDaemon(*pidFile); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case *daemon && !daemonized():
+		if err := startDaemon(*pidFile, *logFile); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ln, activated, err := listen(*bind)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if clients.active() {
+		ln = clients.listener(ln)
+	}
+	addr := *bind
+	if activated {
+		// Inherited sockets are usually bound to all interfaces, but the
+		// certificate is only good for the names we asked for.
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		addr = net.JoinHostPort("localhost", port)
+		infof("Using socket %s from systemd", ln.Addr())
+	}
+
+	domains := []string{"localhost"}
+	for _, v := range vhosts {
+		domains = append(domains, v.host)
+	}
+	if *mdns != "" {
+		name, err := mdnsName(*mdns)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*mdns = name
+		domains = append(domains, *mdns+".local")
+		host, port, err := net.SplitHostPort(ln.Addr().String())
+		if err != nil {
+			log.Fatal(err)
+		}
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := advertiseMDNS(*mdns, uint16(p)); err != nil {
+			log.Fatal(err)
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			log.Printf("Warning: %s is only reachable locally, bind to :%s to serve the LAN", addr, port)
+		}
+		infof("✨ https://%s.local:%s/ ✨", *mdns, port)
+	}
 
-	// Create a temporary directory for the certificate files.
-	dir, err := ioutil.TempDir("", "mkcert")
+	// Create a temporary directory for the certificate files, unless we've
+	// been given somewhere to keep them between runs.
+	dir := *certDir
+	if dir == "" {
+		dir, err = ioutil.TempDir("", "mkcert")
+	} else {
+		err = os.MkdirAll(dir, 0700)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	// fatal exits with v logged, having first shredded the temporary
+	// directory, so that no private key issued into it is left behind.
+	fatal := func(v ...interface{}) {
+		if *certDir == "" {
+			shredDir(dir)
+		}
+		log.Fatal(v...)
+	}
 
-	// Get our certificate.
-	cert, err := mkcert.Exec(
-		// Domains tells mkcert what certificate to generate.
-		mkcert.Domains("localhost"),
-		// RequireTrusted(true) tells Exec to return an error if the CA isn't
+	// Get our certificate. The Manager issues it again on SIGHUP, and issues
+	// certificates for other local names the server's reached by.
+	certs := &mkcert.Manager{HostPolicy: localHostPolicy, Opts: []mkcert.Opt{
+		// RequireTrusted(true) tells the Manager to return an error if the CA isn't
 		// in the trust stores.
 		mkcert.RequireTrusted(true),
 		mkcert.Directory(dir),
-		// CertFile and KeyFile override the default behaviour of generating
-		// the keys in the local directory.
-		// mkcert.CertFile(filepath.Join(dir, "cert.pem")),
-		// mkcert.KeyFile(filepath.Join(dir, "key.pem")),
-	)
+		// Reuse(true) skips generating a new certificate if a matching one
+		// already exists in dir.
+		mkcert.Reuse(*certDir != ""),
+	}}
+	cert, err := certs.Get(domains...)
 	if err != nil {
 		log.Println(err)
 
@@ -43,13 +177,421 @@ func main() {
 		if errors.As(err, &perr) {
 			log.Println("mkcert stderr:", string(perr.Stderr))
 		}
+		if *certDir == "" {
+			shredDir(dir)
+		}
 		os.Exit(1)
 	}
 
-	log.Printf("Using certificate: %#v", cert)
-	log.Printf("✨ https://%s/ ✨", *bind)
+	info, _ := certs.Cert(domains...)
+	started("https://"+addr+"/", info, cert.Leaf)
 
 	// Launch the server.
-	h := http.FileServer(http.Dir("."))
-	log.Fatal(http.ListenAndServeTLS(*bind, cert.File, cert.KeyFile, h))
+	if len(mounts) == 0 {
+		mounts = mountFlags{{prefix: "/", dir: "."}}
+	}
+	mux := http.NewServeMux()
+	var h http.Handler = mux
+	if *livereload {
+		var dirs []string
+		for _, m := range mounts {
+			if m.backend == nil {
+				dirs = append(dirs, m.dir)
+			}
+		}
+		for _, v := range vhosts {
+			dirs = append(dirs, v.dir)
+		}
+		r := newReloader()
+		go func() {
+			if err := r.watch(dirs...); err != nil {
+				log.Printf("Warning: -livereload stopped watching for changes: %v", err)
+			}
+		}()
+		mux.Handle(livereloadPath, r)
+		for _, v := range vhosts {
+			// Patterns with a host take precedence over those without.
+			mux.Handle(v.host+livereloadPath, r)
+		}
+	}
+	if *dav {
+		log.Printf("Warning: -webdav lets anyone who can reach the server modify the served files")
+	}
+	static := func(dir, prefix string) http.Handler {
+		var fs http.Handler = http.FileServer(http.Dir(dir))
+		fs = archiveDir(jsonIndex(fs, http.Dir(dir)), http.Dir(dir))
+		fs = withErrorPages(fs, errorPages)
+		if prefix != "/" {
+			fs = http.StripPrefix(prefix, fs)
+		}
+		if *livereload {
+			fs = injectLivereload(fs)
+		}
+		if *dav {
+			// WebDAV needs the whole path, to understand Destination headers.
+			fs = withWebDAV(fs, dir, prefix)
+		}
+		return fs
+	}
+	for _, m := range mounts {
+		pattern := m.prefix + "/"
+		if m.prefix == "/" {
+			pattern = "/"
+		}
+		if m.backend != nil {
+			infof("Proxying %s to %s", m.prefix, m.backend)
+			// Requests keep their full path, and the backend sees the Host
+			// the client asked for. As with httpsproxy, responses aren't
+			// buffered.
+			target := m.backend
+			mux.Handle(pattern, &httputil.ReverseProxy{
+				Rewrite: func(r *httputil.ProxyRequest) {
+					r.SetURL(target)
+					r.SetXForwarded()
+					r.Out.Host = r.In.Host
+				},
+				FlushInterval: -1,
+			})
+			continue
+		}
+		infof("Serving %s at %s", m.dir, m.prefix)
+		mux.Handle(pattern, static(m.dir, m.prefix))
+	}
+	for _, v := range vhosts {
+		_, port, _ := net.SplitHostPort(addr)
+		infof("Serving %s at https://%s/", v.dir, net.JoinHostPort(v.host, port))
+		mux.Handle(v.host+"/", static(v.dir, "/"))
+	}
+	if *maxBody > 0 {
+		h = limitBody(h, *maxBody)
+	}
+	if *rateLimit > 0 {
+		h = limitRate(h, *rateLimit, *burst)
+	}
+	if *secureHeaders {
+		for k, v := range secureHeaderPreset {
+			if _, ok := headers[k]; !ok {
+				headers.Set(k + ": " + v)
+			}
+		}
+	}
+	if *hsts > 0 {
+		headers.Set(fmt.Sprintf("Strict-Transport-Security: max-age=%d", int64(hsts.Seconds())))
+	}
+	if len(headers) > 0 {
+		h = addHeaders(h, http.Header(headers))
+	}
+	if clients.active() {
+		h = clients.handler(h)
+	}
+	if *dump {
+		out := io.Writer(os.Stderr)
+		if *dumpFile != "" {
+			f, err := os.OpenFile(*dumpFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+			if err != nil {
+				fatal(err)
+			}
+			defer f.Close()
+			out = f
+		}
+		l := log.New(out, "", log.LstdFlags|log.Lmicroseconds)
+		if jsonLog != nil && *dumpFile == "" {
+			l = slog.NewLogLogger(jsonLog.Handler(), slog.LevelInfo)
+		}
+		d := &dumper{log: l, maxBody: *dumpBody}
+		h = d.handler(h)
+	}
+	covered := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		covered[d] = true
+	}
+	tlsConfig := &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			// Names we weren't started with, such as new /etc/hosts
+			// aliases, are given certificates of their own if they're
+			// local, as decided by localHostPolicy.
+			host := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+			if host != "" && !covered[host] {
+				return certs.GetCertificate(hello)
+			}
+			return certs.Get(domains...)
+		},
+	}
+	if *keyLog != "" {
+		f, err := os.OpenFile(*keyLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		tlsConfig.KeyLogWriter = f
+		log.Printf("Warning: writing TLS secrets to %s, anyone with the file can decrypt the traffic", *keyLog)
+	}
+
+	errc := make(chan error, 2)
+	var h3s *http3.Server
+	if *h3 {
+		h3s = &http3.Server{Addr: ln.Addr().String(), Handler: h, TLSConfig: tlsConfig.Clone()}
+		go func() { errc <- h3s.ListenAndServe() }()
+
+		// Advertise HTTP/3 to clients connecting over TCP.
+		tcp := h
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h3s.SetQUICHeaders(w.Header())
+			tcp.ServeHTTP(w, r)
+		})
+	}
+	if daemonized() {
+		if err := writePid(*pidFile); err != nil {
+			fatal(err)
+		}
+	}
+	srv := &http.Server{Handler: h, MaxHeaderBytes: *maxHeader, TLSConfig: tlsConfig}
+	// Left alone, TLS negotiates HTTP/2 with clients that offer it and
+	// HTTP/1.1 with the rest. -http1-only and -h2 pin it to one of them, both
+	// in what ALPN offers and what the server will speak.
+	switch {
+	case *http1Only:
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	case *h2Only:
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP2(true)
+		tlsConfig.NextProtos = []string{"h2"}
+	}
+	go func() { errc <- srv.ServeTLS(ln, "", "") }()
+
+	// Serve until interrupted, then shut down gracefully.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case err = <-errc:
+		case s := <-sig:
+			if s == syscall.SIGHUP {
+				infof("Received %s, reloading the certificate", s)
+				if err := certs.Reload(); err != nil {
+					log.Printf("Reloading: %v", err)
+				}
+				continue
+			}
+			infof("Received %s, shutting down", s)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err = srv.Shutdown(ctx)
+			cancel()
+			if h3s != nil {
+				h3s.Close()
+			}
+		}
+		break
+	}
+
+	if daemonized() {
+		os.Remove(*pidFile)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	// Don't leave the private key lying around in the temporary directory.
+	if *certDir == "" {
+		if err := shredDir(dir); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// shredDir overwrites the files in dir before removing it. This is a best
+// effort: journaling and copy-on-write filesystems may keep the old blocks.
+func shredDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(dir, fi.Name()), os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(make([]byte, fi.Size()))
+		if err == nil {
+			err = f.Sync()
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(dir)
+}
+
+// limitBody wraps h to refuse request bodies of more than n bytes.
+func limitBody(h http.Handler, n int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > n {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// addHeaders wraps h to include hdr in every response.
+func addHeaders(h http.Handler, hdr http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, vs := range hdr {
+			for _, v := range vs {
+				w.Header().Add(k, v)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// secureHeaderPreset are the headers sent with -secure-headers.
+var secureHeaderPreset = map[string]string{
+	"X-Content-Type-Options":       "nosniff",
+	"X-Frame-Options":              "DENY",
+	"Referrer-Policy":              "strict-origin-when-cross-origin",
+	"Cross-Origin-Opener-Policy":   "same-origin",
+	"Cross-Origin-Resource-Policy": "same-origin",
+	"Permissions-Policy":           "camera=(), microphone=(), geolocation=()",
+}
+
+// headerFlags collects the repeatable -header flag.
+type headerFlags http.Header
+
+func (h *headerFlags) String() string {
+	var s []string
+	for k, vs := range *h {
+		for _, v := range vs {
+			s = append(s, k+": "+v)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+func (h *headerFlags) Set(v string) error {
+	colon := strings.Index(v, ":")
+	if colon < 1 {
+		return fmt.Errorf("expected \"Name: value\", got %q", v)
+	}
+	if *h == nil {
+		*h = make(headerFlags)
+	}
+	http.Header(*h).Add(strings.TrimSpace(v[:colon]), strings.TrimSpace(v[colon+1:]))
+	return nil
+}
+
+// mount is a directory served, or a backend proxied to, beneath a URL path
+// prefix.
+type mount struct {
+	prefix  string
+	dir     string
+	backend *url.URL
+}
+
+// mountFlags collects the repeatable -mount and -route flags.
+type mountFlags []mount
+
+func (m *mountFlags) String() string {
+	var s []string
+	for _, mt := range *m {
+		if mt.backend != nil {
+			s = append(s, mt.prefix+"=proxy:"+mt.backend.String())
+			continue
+		}
+		s = append(s, mt.prefix+"="+mt.dir)
+	}
+	return strings.Join(s, ",")
+}
+
+func (m *mountFlags) Set(v string) error {
+	eq := strings.Index(v, "=")
+	if eq < 1 || eq == len(v)-1 {
+		return fmt.Errorf("expected prefix=dir, got %q", v)
+	}
+	return m.add(mount{prefix: v[:eq], dir: v[eq+1:]})
+}
+
+func (m *mountFlags) add(mt mount) error {
+	mt.prefix = path.Clean("/" + mt.prefix)
+	for _, other := range *m {
+		if other.prefix == mt.prefix {
+			return fmt.Errorf("prefix %s mounted twice", mt.prefix)
+		}
+	}
+	*m = append(*m, mt)
+	return nil
+}
+
+// routeFlags adds the repeatable -route flag to the mounts.
+type routeFlags struct{ mounts *mountFlags }
+
+func (r routeFlags) String() string {
+	if r.mounts == nil {
+		return ""
+	}
+	return r.mounts.String()
+}
+
+func (r routeFlags) Set(v string) error {
+	eq := strings.Index(v, "=")
+	colon := strings.Index(v[eq+1:], ":")
+	if eq < 1 || colon < 0 || eq+1+colon == len(v)-1 {
+		return fmt.Errorf("expected prefix=static:dir or prefix=proxy:url, got %q", v)
+	}
+	kind, target := v[eq+1:eq+1+colon], v[eq+2+colon:]
+	switch kind {
+	case "static":
+		return r.mounts.add(mount{prefix: v[:eq], dir: target})
+	case "proxy":
+		u, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("proxy %q must be an absolute http or https URL", target)
+		}
+		return r.mounts.add(mount{prefix: v[:eq], backend: u})
+	}
+	return fmt.Errorf("unknown route kind %q, expected static or proxy", kind)
+}
+
+// vhost is a directory served to requests for a host.
+type vhost struct {
+	host string
+	dir  string
+}
+
+// vhostFlags collects the repeatable -vhost flag.
+type vhostFlags []vhost
+
+func (v *vhostFlags) String() string {
+	var s []string
+	for _, vh := range *v {
+		s = append(s, vh.host+"="+vh.dir)
+	}
+	return strings.Join(s, ",")
+}
+
+func (v *vhostFlags) Set(val string) error {
+	eq := strings.Index(val, "=")
+	if eq < 1 || eq == len(val)-1 {
+		return fmt.Errorf("expected host=dir, got %q", val)
+	}
+	host := strings.TrimSuffix(strings.ToLower(val[:eq]), ".")
+	if strings.ContainsAny(host, "/:") {
+		return fmt.Errorf("expected a host name without a port or path, got %q", val[:eq])
+	}
+	for _, vh := range *v {
+		if vh.host == host {
+			return fmt.Errorf("host %s given twice", host)
+		}
+	}
+	*v = append(*v, vhost{host: host, dir: val[eq+1:]})
+	return nil
 }
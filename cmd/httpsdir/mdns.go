@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// mdnsService is the DNS-SD service type httpsdir is advertised as.
+const mdnsService = "_https._tcp.local."
+
+// cacheFlush marks records for which we are the only authority (RFC 6762
+// section 10.2).
+const cacheFlush = dnsmessage.Class(1 << 15)
+
+// mdnsResponder answers multicast DNS queries for host.local and advertises an
+// _https._tcp service instance pointing at it.
+type mdnsResponder struct {
+	host     dnsmessage.Name // e.g. "myserver.local."
+	instance dnsmessage.Name // e.g. "myserver._https._tcp.local."
+	service  dnsmessage.Name
+	port     uint16
+	conn     *net.UDPConn
+}
+
+// mdnsName returns the DNS label to advertise for the -mdns name, which may
+// be given with or without .local.
+func mdnsName(name string) (string, error) {
+	label := strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(name), "."), ".local")
+	valid := len(label) > 0 && len(label) <= 63 && label[0] != '-' && label[len(label)-1] != '-'
+	for _, c := range label {
+		valid = valid && (c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-')
+	}
+	if !valid {
+		return "", fmt.Errorf("-mdns %q: want a name such as myserver, of up to 63 letters, digits and hyphens", name)
+	}
+	return label, nil
+}
+
+// advertiseMDNS starts responding to mDNS queries for name.local, announcing
+// the service on port.
+func advertiseMDNS(name string, port uint16) error {
+	host, err := dnsmessage.NewName(name + ".local.")
+	if err != nil {
+		return err
+	}
+	instance, err := dnsmessage.NewName(name + "." + mdnsService)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return err
+	}
+	r := &mdnsResponder{
+		host:     host,
+		instance: instance,
+		service:  dnsmessage.MustNewName(mdnsService),
+		port:     port,
+		conn:     conn,
+	}
+	go r.serve()
+	go r.announce()
+	return nil
+}
+
+// announce sends unsolicited responses so that peers learn of us promptly.
+func (r *mdnsResponder) announce() {
+	for i := 0; i < 2; i++ {
+		msg := dnsmessage.Message{Answers: r.records(dnsmessage.TypeALL, r.host, r.instance, r.service)}
+		if err := r.send(msg, mdnsGroup); err != nil {
+			log.Println("mdns:", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (r *mdnsResponder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Println("mdns:", err)
+			return
+		}
+		var p dnsmessage.Parser
+		h, err := p.Start(buf[:n])
+		if err != nil || h.Response {
+			continue
+		}
+		qs, err := p.AllQuestions()
+		if err != nil {
+			continue
+		}
+		var answers []dnsmessage.Resource
+		for _, q := range qs {
+			answers = append(answers, r.records(q.Type, q.Name)...)
+		}
+		if len(answers) == 0 {
+			continue
+		}
+
+		// Queries from a port other than 5353 are legacy unicast queries and
+		// are answered directly (RFC 6762 section 6.7).
+		msg, to := dnsmessage.Message{Answers: answers}, mdnsGroup
+		if from.Port != mdnsGroup.Port {
+			msg.Header.ID, msg.Questions, to = h.ID, qs, from
+			for i := range msg.Answers {
+				msg.Answers[i].Header.Class &^= cacheFlush
+			}
+		}
+		if err := r.send(msg, to); err != nil {
+			log.Println("mdns:", err)
+		}
+	}
+}
+
+func (r *mdnsResponder) send(msg dnsmessage.Message, to *net.UDPAddr) error {
+	msg.Header.Response = true
+	msg.Header.Authoritative = true
+	b, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = r.conn.WriteToUDP(b, to)
+	return err
+}
+
+// records returns the resources answering a question of type t for any of
+// names.
+func (r *mdnsResponder) records(t dnsmessage.Type, names ...dnsmessage.Name) []dnsmessage.Resource {
+	var rs []dnsmessage.Resource
+	add := func(name dnsmessage.Name, typ dnsmessage.Type, class dnsmessage.Class, body dnsmessage.ResourceBody) {
+		if t != typ && t != dnsmessage.TypeALL {
+			return
+		}
+		rs = append(rs, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: name, Type: typ, Class: class, TTL: 120},
+			Body:   body,
+		})
+	}
+	for _, name := range names {
+		switch {
+		case strings.EqualFold(name.String(), r.host.String()):
+			for _, ip := range localIPs() {
+				if ip4 := ip.To4(); ip4 != nil {
+					var a [4]byte
+					copy(a[:], ip4)
+					add(r.host, dnsmessage.TypeA, dnsmessage.ClassINET|cacheFlush, &dnsmessage.AResource{A: a})
+				} else {
+					var a [16]byte
+					copy(a[:], ip)
+					add(r.host, dnsmessage.TypeAAAA, dnsmessage.ClassINET|cacheFlush, &dnsmessage.AAAAResource{AAAA: a})
+				}
+			}
+		case strings.EqualFold(name.String(), r.service.String()):
+			add(r.service, dnsmessage.TypePTR, dnsmessage.ClassINET, &dnsmessage.PTRResource{PTR: r.instance})
+		case strings.EqualFold(name.String(), r.instance.String()):
+			add(r.instance, dnsmessage.TypeSRV, dnsmessage.ClassINET|cacheFlush, &dnsmessage.SRVResource{Target: r.host, Port: r.port})
+			add(r.instance, dnsmessage.TypeTXT, dnsmessage.ClassINET|cacheFlush, &dnsmessage.TXTResource{TXT: []string{"path=/"}})
+		}
+	}
+	return rs
+}
+
+// localIPs returns the unicast addresses of the machine's non-loopback
+// interfaces.
+func localIPs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.IsLoopback() || ipn.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ipn.IP)
+	}
+	return ips
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter applies a token bucket to each client IP.
+type rateLimiter struct {
+	rate  float64 // tokens per second
+	burst float64 // bucket capacity
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limitRate wraps h to allow each client r requests per second, in bursts of
+// up to burst requests. Requests over the limit are refused with 429s.
+func limitRate(h http.Handler, r float64, burst int) http.Handler {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(r)))
+	}
+	l := &rateLimiter{rate: r, burst: float64(burst), buckets: make(map[string]*bucket)}
+	go l.prune(time.Minute)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait := l.take(r.RemoteAddr, time.Now()); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// take removes a token from the bucket of the client at addr, returning zero
+// on success or otherwise how long until a token will be available.
+func (l *rateLimiter) take(addr string, now time.Time) time.Duration {
+	ip, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		ip = addr
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: l.burst}
+		l.buckets[ip] = b
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// prune forgets clients whose buckets have refilled.
+func (l *rateLimiter) prune(every time.Duration) {
+	for now := range time.Tick(every) {
+		full := time.Duration(l.burst / l.rate * float64(time.Second))
+		l.mu.Lock()
+		for ip, b := range l.buckets {
+			if now.Sub(b.last) > full {
+				delete(l.buckets, ip)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
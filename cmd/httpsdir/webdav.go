@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"golang.org/x/net/webdav"
+)
+
+// withWebDAV wraps h, serving dir beneath prefix, to also handle the WebDAV
+// methods so that file managers can mount and modify dir. Plain GETs are left
+// to h, which lists directories.
+func withWebDAV(h http.Handler, dir, prefix string) http.Handler {
+	if prefix == "/" {
+		prefix = ""
+	}
+	dav := &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: webdav.Dir(dir),
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				log.Printf("webdav: %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodPost:
+			h.ServeHTTP(w, r)
+		default:
+			dav.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/icio/mkcert"
+)
+
+func main() {
+	// Flags.
+	bind := flag.String("b", "localhost:12345", "bind host:addr")
+	maxBody := flag.Int64("max-body", 1<<20, "echo at most `bytes` of the request body")
+	flag.Parse()
+
+	// Get our certificate.
+	cert, err := mkcert.Exec(
+		mkcert.Domains("localhost"),
+		mkcert.RequireTrusted(true),
+		// TempDir keeps the certificate files in a temporary directory,
+		// removed by cert.Cleanup.
+		mkcert.TempDir(),
+	)
+	if err != nil {
+		log.Println(err)
+
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+		cert.Cleanup()
+		os.Exit(1)
+	}
+	defer cert.Cleanup()
+
+	log.Printf("Using certificate: %#v", cert)
+	log.Printf("✨ https://%s/ ✨", *bind)
+
+	// Launch the server. Client certificates are requested but not verified
+	// so that their details can be echoed back whoever issued them.
+	srv := &http.Server{
+		Addr:      *bind,
+		Handler:   echoHandler(*maxBody),
+		TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert},
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServeTLS(cert.File, cert.KeyFile) }()
+
+	// Serve until interrupted, then shut down gracefully.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-errc:
+	case s := <-sig:
+		log.Printf("Received %s, shutting down", s)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = srv.Shutdown(ctx)
+		cancel()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// echo describes a request as received by the server.
+type echo struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Proto      string      `json:"proto"`
+	Host       string      `json:"host"`
+	RemoteAddr string      `json:"remote_addr"`
+	Header     http.Header `json:"header"`
+	Trailer    http.Header `json:"trailer,omitempty"`
+	TLS        *tlsInfo    `json:"tls,omitempty"`
+
+	// Body is set when the body is valid UTF-8, and BodyBase64 otherwise.
+	Body          string `json:"body,omitempty"`
+	BodyBase64    string `json:"body_base64,omitempty"`
+	BodyTruncated bool   `json:"body_truncated,omitempty"`
+}
+
+// tlsInfo describes the negotiated TLS connection.
+type tlsInfo struct {
+	Version     string   `json:"version"`
+	CipherSuite string   `json:"cipher_suite"`
+	ALPN        string   `json:"alpn,omitempty"`
+	ServerName  string   `json:"server_name,omitempty"`
+	Resumed     bool     `json:"resumed"`
+	ClientCerts []string `json:"client_certs,omitempty"`
+}
+
+func echoHandler(maxBody int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		e := echo{
+			Method:     r.Method,
+			URL:        r.URL.String(),
+			Proto:      r.Proto,
+			Host:       r.Host,
+			RemoteAddr: r.RemoteAddr,
+			Header:     r.Header,
+			Trailer:    r.Trailer,
+		}
+		if int64(len(body)) > maxBody {
+			body, e.BodyTruncated = body[:maxBody], true
+		}
+		if utf8.Valid(body) {
+			e.Body = string(body)
+		} else {
+			e.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+		}
+		if cs := r.TLS; cs != nil {
+			e.TLS = &tlsInfo{
+				Version:     tls.VersionName(cs.Version),
+				CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+				ALPN:        cs.NegotiatedProtocol,
+				ServerName:  cs.ServerName,
+				Resumed:     cs.DidResume,
+			}
+			for _, c := range cs.PeerCertificates {
+				e.TLS.ClientCerts = append(e.TLS.ClientCerts, c.Subject.String())
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(e)
+	})
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/icio/mkcert"
+)
+
+func main() {
+	// Flags.
+	bind := flag.String("b", "localhost:12345", "bind host:addr")
+	backend := flag.String("backend", "http://localhost:3000", "proxy requests to `url`")
+	host := flag.String("host", "", "send `name` as the Host to the backend, rather than the client's")
+	var setHeaders headerFlags
+	var stripHeaders stripFlags
+	flag.Var(&setHeaders, "header", "set `\"Name: value\"` on requests to the backend, such as an Authorization (repeatable)")
+	flag.Var(&stripHeaders, "strip-header", "remove the `name`d header from requests to the backend (repeatable)")
+	rewriteLoc := flag.Bool("rewrite-location", true, "rewrite redirects to the backend's origin, or the -host, back to the proxy's")
+	var routes routeFlags
+	flag.Var(&routes, "route", "proxy requests under `prefix=url` to url, rather than the -backend (repeatable)")
+	backendProto := flag.String("backend-proto", "http1", "speak `proto` to backends: http1, h2 over https, or h2c, HTTP/2 without TLS, over http")
+	flag.Parse()
+
+	target, err := url.Parse(*backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if target.Scheme == "" || target.Host == "" {
+		log.Fatalf("-backend %q must be an absolute URL", *backend)
+	}
+	if rt := routes.match("/"); rt == nil {
+		routes = append(routes, route{prefix: "/", backend: target})
+	} else if flagSet("backend") {
+		log.Fatalf("-backend and -route %s=%s both give the backend for /, use one", rt.prefix, rt.backend)
+	}
+	transport, err := backendTransport(*backendProto, routes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Get our certificate. The Manager keeps it in a temporary directory,
+	// and issues it again on SIGHUP.
+	certs := &mkcert.Manager{Opts: []mkcert.Opt{mkcert.RequireTrusted(true)}}
+	cert, err := certs.Get("localhost")
+	if err != nil {
+		log.Println(err)
+
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+		os.Exit(1)
+	}
+
+	log.Printf("Using certificate for %s, expiring %s", strings.Join(cert.Leaf.DNSNames, ", "), cert.Leaf.NotAfter.Format(time.RFC3339))
+	for _, rt := range routes {
+		log.Printf("✨ https://%s%s → %s ✨", *bind, rt.prefix, rt.backend)
+	}
+
+	// Launch the proxy. Requests go to the backend of the longest -route
+	// prefix they're under, keeping their full path, or else to -backend.
+	// The backend sees the Host the client asked for, unless it's given with
+	// -host, and the X-Forwarded-For, -Host and -Proto it connected with.
+	// WebSocket upgrades are passed through, and responses are written to
+	// the client as soon as they arrive, so that server-sent events, long
+	// polls and progress output aren't held up in a buffer.
+	// Trailers, such as gRPC's status, are passed back after the body.
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(routes.match(r.In.URL.Path).backend)
+			r.SetXForwarded()
+			r.Out.Host = r.In.Host
+			if *host != "" {
+				r.Out.Host = *host
+			}
+			for _, name := range stripHeaders {
+				r.Out.Header.Del(name)
+			}
+			for name, values := range setHeaders {
+				r.Out.Header[name] = values
+			}
+			r.Out = r.Out.WithContext(context.WithValue(r.Out.Context(), clientHostKey{}, r.In.Host))
+		},
+		Transport:     transport,
+		FlushInterval: -1,
+	}
+	if *rewriteLoc {
+		var backends []string
+		for _, rt := range routes {
+			backends = append(backends, rt.backend.Host)
+		}
+		if *host != "" {
+			backends = append(backends, *host)
+		}
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			rewriteLocation(resp, backends)
+			return nil
+		}
+	}
+	tlsConfig := &tls.Config{
+		// Whatever the client asked for, it gets the localhost certificate.
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certs.Get("localhost")
+		},
+	}
+	srv := &http.Server{Addr: *bind, Handler: proxy, TLSConfig: tlsConfig}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServeTLS("", "") }()
+
+	// Serve until interrupted, then shut down gracefully.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case err = <-errc:
+		case s := <-sig:
+			if s == syscall.SIGHUP {
+				log.Printf("Received %s, reloading the certificate", s)
+				if err := certs.Reload(); err != nil {
+					log.Printf("Reloading: %v", err)
+				}
+				continue
+			}
+			log.Printf("Received %s, shutting down", s)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err = srv.Shutdown(ctx)
+			cancel()
+		}
+		break
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// backendTransport returns the transport speaking proto to the routes'
+// backends. HTTP/2 is only spoken over TLS to https backends, and h2c only to
+// http ones, so that each backend's scheme must suit proto.
+func backendTransport(proto string, routes routeFlags) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	var p http.Protocols
+	var scheme string
+	switch proto {
+	case "http1":
+		return t, nil
+	case "h2":
+		p.SetHTTP2(true)
+		scheme = "https"
+	case "h2c":
+		p.SetUnencryptedHTTP2(true)
+		scheme = "http"
+	default:
+		return nil, fmt.Errorf("unknown -backend-proto %q, expected http1, h2 or h2c", proto)
+	}
+	for _, rt := range routes {
+		if rt.backend.Scheme != scheme {
+			return nil, fmt.Errorf("-backend-proto %s needs %s backends, got %s", proto, scheme, rt.backend)
+		}
+	}
+	t.Protocols = &p
+	return t, nil
+}
+
+// clientHostKey is the context key of the host the client asked the proxy for,
+// in requests to the backend.
+type clientHostKey struct{}
+
+// rewriteLocation points the Location of resp back at the proxy if it
+// redirects to one of the backends, given as host:port, so that clients
+// aren't sent to an origin they can't reach or which has no certificate.
+func rewriteLocation(resp *http.Response, backends []string) {
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return
+	}
+	u, err := url.Parse(loc)
+	if err != nil || u.Host == "" {
+		return
+	}
+	clientHost, _ := resp.Request.Context().Value(clientHostKey{}).(string)
+	if clientHost == "" {
+		return
+	}
+	for _, b := range backends {
+		if strings.EqualFold(u.Host, b) {
+			u.Scheme, u.Host = "https", clientHost
+			resp.Header.Set("Location", u.String())
+			return
+		}
+	}
+}
+
+// headerFlags collects the repeatable -header flag.
+type headerFlags http.Header
+
+func (h *headerFlags) String() string {
+	var s []string
+	for k, vs := range *h {
+		for _, v := range vs {
+			s = append(s, k+": "+v)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+func (h *headerFlags) Set(v string) error {
+	colon := strings.Index(v, ":")
+	if colon < 1 {
+		return fmt.Errorf("expected \"Name: value\", got %q", v)
+	}
+	if *h == nil {
+		*h = make(headerFlags)
+	}
+	http.Header(*h).Add(strings.TrimSpace(v[:colon]), strings.TrimSpace(v[colon+1:]))
+	return nil
+}
+
+// stripFlags collects the repeatable -strip-header flag.
+type stripFlags []string
+
+func (s *stripFlags) String() string { return strings.Join(*s, ", ") }
+
+func (s *stripFlags) Set(v string) error {
+	*s = append(*s, strings.TrimSpace(v))
+	return nil
+}
+
+// route is a backend proxied to beneath a URL path prefix.
+type route struct {
+	prefix  string
+	backend *url.URL
+}
+
+// routeFlags collects the repeatable -route flag.
+type routeFlags []route
+
+func (r *routeFlags) String() string {
+	var s []string
+	for _, rt := range *r {
+		s = append(s, rt.prefix+"="+rt.backend.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (r *routeFlags) Set(v string) error {
+	eq := strings.Index(v, "=")
+	if eq < 1 || eq == len(v)-1 {
+		return fmt.Errorf("expected prefix=url, got %q", v)
+	}
+	u, err := url.Parse(v[eq+1:])
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("backend %q must be an absolute http or https URL", v[eq+1:])
+	}
+	prefix := path.Clean("/" + v[:eq])
+	for _, other := range *r {
+		if other.prefix == prefix {
+			return fmt.Errorf("prefix %s routed twice", prefix)
+		}
+	}
+	*r = append(*r, route{prefix: prefix, backend: u})
+	return nil
+}
+
+// match returns the route with the longest prefix that p is under, matching
+// whole path segments, so that /api covers /api and /api/users but not
+// /apis. It returns nil if there's none.
+func (r routeFlags) match(p string) *route {
+	var best *route
+	for i, rt := range r {
+		under := rt.prefix == "/" || p == rt.prefix || strings.HasPrefix(p, rt.prefix+"/")
+		if under && (best == nil || len(rt.prefix) > len(best.prefix)) {
+			best = &r[i]
+		}
+	}
+	return best
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
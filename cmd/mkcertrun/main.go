@@ -0,0 +1,54 @@
+// Command mkcertrun runs a command set up to trust the mkcert root CA, so
+// that tools such as Node.js, Python, curl and git accept the certificates of
+// local HTTPS services:
+//
+//	mkcertrun -- npm test
+//
+// See mkcert.Run for the envvars set.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/icio/mkcert"
+)
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [--] command [args...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The command gets the signals typed at the terminal too, so give it the
+	// chance to exit by itself rather than killing it.
+	signal.Ignore(os.Interrupt, syscall.SIGTERM)
+
+	err := mkcert.Run(context.Background(), flag.Args())
+	if perr, ok := err.(*exec.ExitError); ok {
+		// The command failed, so exit the same way.
+		os.Exit(perr.ExitCode())
+	}
+	if err != nil {
+		log.Println(err)
+
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+		os.Exit(1)
+	}
+}
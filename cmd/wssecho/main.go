@@ -0,0 +1,140 @@
+// Command wssecho serves a secure WebSocket endpoint which echoes every
+// message back to the client, using a certificate from mkcert.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/icio/mkcert"
+)
+
+func main() {
+	// Flags.
+	bind := flag.String("b", "localhost:12345", "bind host:addr")
+	subprotocols := flag.String("subprotocols", "", "comma-separated `protocols` to negotiate, in order of preference")
+	ping := flag.Duration("ping", 30*time.Second, "ping clients every `interval`, closing those which don't respond (0 to disable)")
+	flag.Parse()
+
+	// Get our certificate.
+	cert, err := mkcert.Exec(
+		mkcert.Domains("localhost"),
+		mkcert.RequireTrusted(true),
+		// TempDir keeps the certificate files in a temporary directory,
+		// removed by cert.Cleanup.
+		mkcert.TempDir(),
+	)
+	if err != nil {
+		log.Println(err)
+
+		var perr *exec.ExitError
+		if errors.As(err, &perr) {
+			log.Println("mkcert stderr:", string(perr.Stderr))
+		}
+		cert.Cleanup()
+		os.Exit(1)
+	}
+	defer cert.Cleanup()
+
+	log.Printf("Using certificate: %#v", cert)
+	log.Printf("✨ wss://%s/ ✨", *bind)
+
+	e := &echoer{
+		upgrader: websocket.Upgrader{
+			// Browsers on any origin are welcome to test against us.
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		ping: *ping,
+	}
+	if *subprotocols != "" {
+		e.upgrader.Subprotocols = strings.Split(*subprotocols, ",")
+	}
+	srv := &http.Server{Addr: *bind, Handler: e}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServeTLS(cert.File, cert.KeyFile) }()
+
+	// Serve until interrupted, then shut down gracefully.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-errc:
+	case s := <-sig:
+		log.Printf("Received %s, shutting down", s)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = srv.Shutdown(ctx)
+		cancel()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// echoer upgrades requests to WebSockets and echoes their messages.
+type echoer struct {
+	upgrader websocket.Upgrader
+	ping     time.Duration
+}
+
+func (e *echoer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "expected a WebSocket upgrade", http.StatusUpgradeRequired)
+		return
+	}
+	conn, err := e.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already responded to the client.
+		log.Printf("%s: %v", r.RemoteAddr, err)
+		return
+	}
+	defer conn.Close()
+	log.Printf("%s: connected (subprotocol %q)", r.RemoteAddr, conn.Subprotocol())
+
+	if e.ping > 0 {
+		// Clients get two intervals to respond to each ping.
+		conn.SetReadDeadline(time.Now().Add(2 * e.ping))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * e.ping))
+		})
+		done := make(chan struct{})
+		defer close(done)
+		go e.pinger(conn, done)
+	}
+
+	for {
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
+			log.Printf("%s: disconnected: %v", r.RemoteAddr, err)
+			return
+		}
+		if err := conn.WriteMessage(typ, msg); err != nil {
+			log.Printf("%s: %v", r.RemoteAddr, err)
+			return
+		}
+	}
+}
+
+func (e *echoer) pinger(conn *websocket.Conn, done <-chan struct{}) {
+	t := time.NewTicker(e.ping)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(e.ping)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package mkcert
+
+import "os/exec"
+
+// Command returns the mkcert command Exec would run for opts, with its
+// arguments, environment and directory set, without running it. It's for
+// programs wanting control over how mkcert runs, such as in a pty or with
+// other credentials, which can then parse its combined output with
+// mkcertout.Parse. Without Domains, the command reports on the CA, as for
+// TrustStatus.
+//
+// Unlike Exec, the command is run regardless of Reuse, and doesn't wait for
+// other commands creating the same CA, so concurrent commands for a new CA
+// should be run one at a time. As nothing would remove it, TempDir can't be
+// used. Invalid options are reported as an *OptionError, and a missing
+// mkcert as ErrBinaryNotFound.
+func Command(opts ...Opt) (*exec.Cmd, error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	p.Domains = p.normalizedDomains()
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if p.TempDir {
+		return nil, &OptionError{[]string{"TempDir"}, "Command can't use it, as nothing would remove the directory"}
+	}
+	cmd, _, err := command(p, p.args()...)
+	return cmd, err
+}
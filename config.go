@@ -0,0 +1,132 @@
+package mkcert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// Config is a set of options in a form which can be read from a
+// configuration file, so that tools embedding this package can let their
+// users configure mkcert in the tool's own file, such as by embedding a
+// Config in theirs. Each field corresponds to the option of the same name,
+// and is left out of Opts when it's the zero value. The field tags name the
+// fields in snake_case for encoding/json, and for YAML and TOML decoders
+// using the yaml and toml tags. OptsFromConfig reads a Config from JSON,
+// YAML or TOML.
+type Config struct {
+	Domains              []string `json:"domains,omitempty" yaml:"domains,omitempty" toml:"domains,omitempty"`
+	Directory            string   `json:"directory,omitempty" yaml:"directory,omitempty" toml:"directory,omitempty"`
+	CertFile             string   `json:"cert_file,omitempty" yaml:"cert_file,omitempty" toml:"cert_file,omitempty"`
+	KeyFile              string   `json:"key_file,omitempty" yaml:"key_file,omitempty" toml:"key_file,omitempty"`
+	TempDir              bool     `json:"temp_dir,omitempty" yaml:"temp_dir,omitempty" toml:"temp_dir,omitempty"`
+	Binary               string   `json:"binary,omitempty" yaml:"binary,omitempty" toml:"binary,omitempty"`
+	BinaryNames          []string `json:"binary_names,omitempty" yaml:"binary_names,omitempty" toml:"binary_names,omitempty"`
+	CARoot               string   `json:"caroot,omitempty" yaml:"caroot,omitempty" toml:"caroot,omitempty"`
+	RequireTrusted       bool     `json:"require_trusted,omitempty" yaml:"require_trusted,omitempty" toml:"require_trusted,omitempty"`
+	RequireTrustedStores []string `json:"require_trusted_stores,omitempty" yaml:"require_trusted_stores,omitempty" toml:"require_trusted_stores,omitempty"`
+	Reuse                bool     `json:"reuse,omitempty" yaml:"reuse,omitempty" toml:"reuse,omitempty"`
+	// RenewBefore is a duration such as "720h", as parsed by
+	// time.ParseDuration.
+	RenewBefore string `json:"renew_before,omitempty" yaml:"renew_before,omitempty" toml:"renew_before,omitempty"`
+	// KeyType is "rsa", the default, or "ecdsa" for the ECDSA option.
+	KeyType    string `json:"key_type,omitempty" yaml:"key_type,omitempty" toml:"key_type,omitempty"`
+	ClientAuth bool   `json:"client_auth,omitempty" yaml:"client_auth,omitempty" toml:"client_auth,omitempty"`
+	Wildcards  bool   `json:"wildcards,omitempty" yaml:"wildcards,omitempty" toml:"wildcards,omitempty"`
+	Keychain   string `json:"keychain,omitempty" yaml:"keychain,omitempty" toml:"keychain,omitempty"`
+	// LocalhostAliases is set for the LocalhostAliases option.
+	LocalhostAliases bool `json:"localhost_aliases,omitempty" yaml:"localhost_aliases,omitempty" toml:"localhost_aliases,omitempty"`
+	Overwrite        bool `json:"overwrite,omitempty" yaml:"overwrite,omitempty" toml:"overwrite,omitempty"`
+	// SecureEnv and KeepEnv are the SecureEnv option and its arguments.
+	SecureEnv bool     `json:"secure_env,omitempty" yaml:"secure_env,omitempty" toml:"secure_env,omitempty"`
+	KeepEnv   []string `json:"keep_env,omitempty" yaml:"keep_env,omitempty" toml:"keep_env,omitempty"`
+	// Audit is set for the Audit option, with AuditLabel its label.
+	Audit      bool   `json:"audit,omitempty" yaml:"audit,omitempty" toml:"audit,omitempty"`
+	AuditLabel string `json:"audit_label,omitempty" yaml:"audit_label,omitempty" toml:"audit_label,omitempty"`
+	AuditFile  string `json:"audit_file,omitempty" yaml:"audit_file,omitempty" toml:"audit_file,omitempty"`
+}
+
+// OptsFromConfig reads a Config from r, returning its options. The format is
+// told from the content: TOML if it begins, past comments, with a table
+// header or a key = value line, and otherwise YAML, which includes JSON.
+// Unknown fields are an error.
+func OptsFromConfig(r io.Reader) ([]Opt, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("mkcert: config: %w", err)
+	}
+	var c Config
+	if isTOML(b) {
+		md, err := toml.Decode(string(b), &c)
+		if err == nil {
+			if keys := md.Undecoded(); len(keys) > 0 {
+				err = fmt.Errorf("unknown field %q", keys[0].String())
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("mkcert: config: %w", err)
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("mkcert: config: %w", err)
+		}
+	}
+	return c.Opts()
+}
+
+// isTOML reports whether the first line of b other than blanks and comments
+// is a TOML table header or assignment. Neither is a YAML mapping, as a
+// Config must be.
+func isTOML(b []byte) bool {
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return true
+		}
+		eq, colon := strings.Index(line, "="), strings.Index(line, ":")
+		return eq > 0 && (colon < 0 || eq < colon)
+	}
+	return false
+}
+
+// Opts returns the options c configures, or an error if any of its values
+// can't be parsed. Options which can't be used together are reported by
+// Exec, as an *OptionError.
+func (c Config) Opts() ([]Opt, error) {
+	// The fields of Request with the same name and type are copied over.
+	var r Request
+	src, dst := reflect.ValueOf(c), reflect.ValueOf(&r).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		f := dst.FieldByName(src.Type().Field(i).Name)
+		if f.IsValid() && f.Type() == src.Field(i).Type() {
+			f.Set(src.Field(i))
+		}
+	}
+	if c.RenewBefore != "" {
+		d, err := time.ParseDuration(c.RenewBefore)
+		if err != nil {
+			return nil, fmt.Errorf("mkcert: config: renew_before: %w", err)
+		}
+		r.RenewBefore = d
+	}
+	switch c.KeyType {
+	case "", "rsa":
+	case "ecdsa":
+		r.ECDSA = true
+	default:
+		return nil, fmt.Errorf("mkcert: config: unknown key_type %q, expected rsa or ecdsa", c.KeyType)
+	}
+	return r.Opts(), nil
+}
@@ -0,0 +1,40 @@
+package mkcert_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icio/mkcert"
+)
+
+func TestOptsFromConfig(t *testing.T) {
+	want := mkcert.Request{Domains: []string{"a.test", "b.test"}, ECDSA: true, RenewBefore: 720 * time.Hour}
+	for name, config := range map[string]string{
+		"json": `{"domains": ["a.test", "b.test"], "key_type": "ecdsa", "renew_before": "720h"}`,
+		"yaml": "# mkcert\ndomains: [a.test, b.test]\nkey_type: ecdsa\nrenew_before: 720h\n",
+		"toml": "# mkcert\ndomains = [\"a.test\", \"b.test\"]\nkey_type = \"ecdsa\"\nrenew_before = \"720h\"\n",
+	} {
+		opts, err := mkcert.OptsFromConfig(strings.NewReader(config))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		var got mkcert.Request
+		got.Apply(opts...)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+
+	for name, config := range map[string]string{
+		"json": `{"domain": ["a.test"]}`,
+		"yaml": "domain: [a.test]\n",
+		"toml": "domain = [\"a.test\"]\n",
+	} {
+		if _, err := mkcert.OptsFromConfig(strings.NewReader(config)); err == nil {
+			t.Errorf("%s: unknown field accepted", name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package mkcert
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// LocalhostAliases has Exec also cover localhost, 127.0.0.1 and ::1, if
+// they're not among the Domains already, so that clients connecting over
+// IPv6 or by address trust the certificate as well as those using the name.
+func LocalhostAliases() Opt {
+	return func(p *params) { p.LocalhostAliases = true }
+}
+
+// Wildcards has Exec also cover *.foo.test for each host name foo.test among
+// the Domains, so that subdomains which come and go, such as one per feature
+// branch, don't each need a certificate. A wildcard only covers a single
+// label, so it doesn't cover foo.test itself or a.b.foo.test. Single-label
+// names such as localhost are left alone, as browsers refuse wildcards
+// directly under a top-level domain like *.localhost, as are IP addresses,
+// emails, URIs and names which are already wildcards.
+func Wildcards(wildcards bool) Opt {
+	return func(p *params) { p.Wildcards = wildcards }
+}
+
+// addWildcards returns the normalized domains followed by the wildcards
+// covering the subdomains of their host names, as described by Wildcards.
+func addWildcards(domains []string) []string {
+	all := append([]string(nil), domains...)
+	for _, d := range domains {
+		if strings.Contains(d, "@") || strings.Contains(d, "://") || net.ParseIP(d) != nil {
+			continue
+		}
+		if strings.HasPrefix(d, "*.") || !strings.Contains(d, ".") {
+			continue
+		}
+		all = append(all, "*."+d)
+	}
+	return all
+}
+
+// normalizedDomains returns the Domains of p normalized, with those added by
+// LocalhostAliases and Wildcards.
+func (p params) normalizedDomains() []string {
+	domains := p.Domains
+	if p.LocalhostAliases && len(domains) > 0 {
+		domains = append(append([]string(nil), domains...), "localhost", "127.0.0.1", "::1")
+	}
+	domains = normalizeDomains(domains)
+	if p.Wildcards {
+		domains = normalizeDomains(addWildcards(domains))
+	}
+	return domains
+}
+
+// normalizeDomains returns domains in the form given to mkcert: host names
+// lowercased without trailing dots, IP addresses in their canonical form, and
+// duplicates removed. The first domain names the certificate files, so it
+// stays first; the rest are sorted so the order they're requested in doesn't
+// matter. Email addresses and URIs are left as they are.
+func normalizeDomains(domains []string) []string {
+	var norm []string
+	seen := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		d = strings.TrimSpace(d)
+		switch {
+		case strings.Contains(d, "@") || strings.Contains(d, "://"):
+		case net.ParseIP(d) != nil:
+			d = net.ParseIP(d).String()
+		default:
+			d = strings.ToLower(strings.TrimRight(d, "."))
+		}
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		norm = append(norm, d)
+	}
+	if len(norm) > 1 {
+		sort.Strings(norm[1:])
+	}
+	return norm
+}
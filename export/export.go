@@ -0,0 +1,254 @@
+// Package export converts mkcert certificates and keys into the formats
+// expected by other TLS stacks: PKCS#12 and JKS keystores, DER, PEM bundles
+// and Kubernetes TLS secrets, and DANE TLSA record data.
+package export
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/icio/mkcert"
+	keystore "github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Bundle is a certificate with its private key and the root CA which issued
+// it.
+type Bundle struct {
+	Leaf *x509.Certificate
+	Key  crypto.PrivateKey
+	Root *x509.Certificate
+}
+
+// Load reads the certificate and key files of cert and the root CA from its
+// CARoot.
+func Load(cert mkcert.Cert) (*Bundle, error) {
+	return LoadFiles(cert.File, cert.KeyFile, filepath.Join(cert.CARoot, "rootCA.pem"))
+}
+
+// LoadFiles reads a certificate and its private key, and the root CA
+// certificate at rootFile, from PEM files.
+func LoadFiles(certFile, keyFile, rootFile string) (*Bundle, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("export: %s and %s: %w", certFile, keyFile, err)
+	}
+	// Before Go 1.23, X509KeyPair doesn't fill in pair.Leaf.
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("export: %s: %w", certFile, err)
+	}
+	rootPEM, err := ioutil.ReadFile(rootFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(rootPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("export: no certificate in " + rootFile)
+	}
+	root, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("export: %s: %w", rootFile, err)
+	}
+	return &Bundle{Leaf: leaf, Key: pair.PrivateKey, Root: root}, nil
+}
+
+// DER returns the leaf certificate in DER form.
+func (b *Bundle) DER() []byte {
+	return b.Leaf.Raw
+}
+
+// FullChain returns the PEM-encoded leaf certificate followed by the root CA.
+func (b *Bundle) FullChain() []byte {
+	return append(certPEM(b.Leaf), certPEM(b.Root)...)
+}
+
+// CombinedPEM returns the PEM-encoded leaf certificate followed by its
+// private key, as expected by HAProxy and similar.
+func (b *Bundle) CombinedPEM() ([]byte, error) {
+	key, err := b.keyPEM()
+	if err != nil {
+		return nil, err
+	}
+	return append(certPEM(b.Leaf), key...), nil
+}
+
+// PKCS12 returns a PKCS#12 keystore protected by password, using the modern
+// AES-256 and PBKDF2 algorithms supported by OpenSSL 1.1.1+ and Java 8u301+.
+func (b *Bundle) PKCS12(password string) ([]byte, error) {
+	return pkcs12.Modern.Encode(b.Key, b.Leaf, []*x509.Certificate{b.Root}, password)
+}
+
+// LegacyPKCS12 returns a PKCS#12 keystore protected by password using the
+// 3DES algorithms which older clients, including macOS Keychain, require.
+func (b *Bundle) LegacyPKCS12(password string) ([]byte, error) {
+	return pkcs12.LegacyDES.Encode(b.Key, b.Leaf, []*x509.Certificate{b.Root}, password)
+}
+
+// JKS returns a Java keystore holding the key and certificate chain under
+// alias. The keystore and the key are both protected by password, which
+// must be at least six characters.
+func (b *Bundle) JKS(alias, password string) ([]byte, error) {
+	key, err := x509.MarshalPKCS8PrivateKey(b.Key)
+	if err != nil {
+		return nil, err
+	}
+	ks := keystore.New(keystore.WithMinPasswordLen(6))
+	err = ks.SetPrivateKeyEntry(alias, keystore.PrivateKeyEntry{
+		CreationTime: time.Now(),
+		PrivateKey:   key,
+		CertificateChain: []keystore.Certificate{
+			{Type: "X509", Content: b.Leaf.Raw},
+			{Type: "X509", Content: b.Root.Raw},
+		},
+	}, []byte(password))
+	if err != nil {
+		return nil, fmt.Errorf("export: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := ks.Store(&buf, []byte(password)); err != nil {
+		return nil, fmt.Errorf("export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// KubernetesSecret returns the YAML manifest of a kubernetes.io/tls Secret
+// named name holding the certificate, key and root CA. The namespace is
+// omitted when blank.
+func (b *Bundle) KubernetesSecret(name, namespace string) ([]byte, error) {
+	key, err := b.keyPEM()
+	if err != nil {
+		return nil, err
+	}
+	enc := base64.StdEncoding.EncodeToString
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: %q\n", name)
+	if namespace != "" {
+		fmt.Fprintf(&buf, "  namespace: %q\n", namespace)
+	}
+	fmt.Fprintf(&buf, "type: kubernetes.io/tls\ndata:\n")
+	fmt.Fprintf(&buf, "  tls.crt: %s\n", enc(certPEM(b.Leaf)))
+	fmt.Fprintf(&buf, "  tls.key: %s\n", enc(key))
+	fmt.Fprintf(&buf, "  ca.crt: %s\n", enc(certPEM(b.Root)))
+	return buf.Bytes(), nil
+}
+
+// TLSA returns the data of a DNS TLSA record for DANE, such as "3 1 1 ab12…",
+// with the certificate usage, selector and matching type given. Usages 0
+// (PKIX-TA) and 2 (DANE-TA) associate the root CA, and 1 (PKIX-EE) and 3
+// (DANE-EE) the leaf. Selector 0 matches the whole certificate and 1 its
+// SubjectPublicKeyInfo. Matching type 0 gives them in full, 1 their SHA-256
+// digest and 2 their SHA-512 digest.
+func (b *Bundle) TLSA(usage, selector, matching uint8) ([]byte, error) {
+	var c *x509.Certificate
+	switch usage {
+	case 0, 2:
+		c = b.Root
+	case 1, 3:
+		c = b.Leaf
+	default:
+		return nil, fmt.Errorf("export: unknown TLSA usage %d, expected 0 to 3", usage)
+	}
+	var data []byte
+	switch selector {
+	case 0:
+		data = c.Raw
+	case 1:
+		data = c.RawSubjectPublicKeyInfo
+	default:
+		return nil, fmt.Errorf("export: unknown TLSA selector %d, expected 0 or 1", selector)
+	}
+	switch matching {
+	case 0:
+	case 1:
+		sum := sha256.Sum256(data)
+		data = sum[:]
+	case 2:
+		sum := sha512.Sum512(data)
+		data = sum[:]
+	default:
+		return nil, fmt.Errorf("export: unknown TLSA matching type %d, expected 0 to 2", matching)
+	}
+	return []byte(fmt.Sprintf("%d %d %d %x\n", usage, selector, matching, data)), nil
+}
+
+// Formats are the names of the formats Encode supports.
+var Formats = []string{"pkcs12", "pkcs12-legacy", "jks", "der", "fullchain", "pem", "k8s", "tlsa"}
+
+// Options are the settings of the formats which need them.
+type Options struct {
+	// Password protects pkcs12 and jks keystores.
+	Password string
+	// Alias names the key in jks keystores.
+	Alias string
+	// Name and Namespace are those of the k8s Secret.
+	Name, Namespace string
+	// TLSAUsage, TLSASelector and TLSAMatching are the fields of the tlsa
+	// record, as described by TLSA.
+	TLSAUsage, TLSASelector, TLSAMatching uint8
+}
+
+// Encode returns b in the named format, one of Formats: pkcs12 for PKCS12,
+// pkcs12-legacy for LegacyPKCS12, jks for JKS, der for DER, fullchain for
+// FullChain, pem for CombinedPEM, k8s for KubernetesSecret, and tlsa for
+// TLSA.
+func (b *Bundle) Encode(format string, o Options) ([]byte, error) {
+	switch format {
+	case "pkcs12":
+		return b.PKCS12(o.Password)
+	case "pkcs12-legacy":
+		return b.LegacyPKCS12(o.Password)
+	case "jks":
+		return b.JKS(o.Alias, o.Password)
+	case "der":
+		return b.DER(), nil
+	case "fullchain":
+		return b.FullChain(), nil
+	case "pem":
+		return b.CombinedPEM()
+	case "k8s":
+		return b.KubernetesSecret(o.Name, o.Namespace)
+	case "tlsa":
+		return b.TLSA(o.TLSAUsage, o.TLSASelector, o.TLSAMatching)
+	}
+	return nil, fmt.Errorf("export: unknown format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+}
+
+// Private reports whether the format holds the private key, and so should
+// only be readable by its owner.
+func Private(format string) bool {
+	return format != "der" && format != "fullchain" && format != "tlsa"
+}
+
+func (b *Bundle) keyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(b.Key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func certPEM(c *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+}
@@ -0,0 +1,45 @@
+package mkcert
+
+import "sync"
+
+// flights coalesces concurrent Exec calls.
+var flights flightGroup
+
+// flightGroup deduplicates concurrent calls with the same key, in the manner
+// of golang.org/x/sync/singleflight.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	cert Cert
+	err  error
+}
+
+// do calls fn, unless a call with the same key is already in progress, in
+// which case it waits for and returns that call's result.
+func (g *flightGroup) do(key string, fn func() (Cert, error)) (Cert, error) {
+	g.mu.Lock()
+	if f, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return f.cert, f.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f := &flight{done: make(chan struct{})}
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	f.cert, f.err = fn()
+	return f.cert, f.err
+}
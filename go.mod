@@ -1,3 +1,13 @@
 module github.com/icio/mkcert
 
-go 1.13
+go 1.21.0
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/pavlo-v-chernykh/keystore-go/v4 v4.5.0
+	go.yaml.in/yaml/v3 v3.0.5
+	golang.org/x/sys v0.10.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
+)
+
+require golang.org/x/crypto v0.11.0
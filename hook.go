@@ -0,0 +1,68 @@
+package mkcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+)
+
+// Hook is run after a certificate is reissued, such as to have a server using
+// it reload. See Manager.OnRenew.
+type Hook func(cert Cert) error
+
+// CommandHook returns a Hook running the program name with args, which are
+// text/template templates executed with the Cert, for example:
+//
+//	CommandHook("docker", "compose", "restart", "web")
+//	CommandHook("cp", "{{.File}}", "/etc/nginx/certs/app.pem")
+//
+// The command also has CERT_FILE, KEY_FILE and DOMAINS (comma-separated) in
+// its environment. Its combined output is included in the ExitError
+// returned if it fails.
+func CommandHook(name string, args ...string) Hook {
+	return func(cert Cert) error {
+		argv := make([]string, len(args))
+		for i, arg := range args {
+			t, err := template.New("arg").Option("missingkey=error").Parse(arg)
+			if err != nil {
+				return fmt.Errorf("mkcert: hook %s: %w", name, err)
+			}
+			var b strings.Builder
+			if err := t.Execute(&b, cert); err != nil {
+				return fmt.Errorf("mkcert: hook %s: %w", name, err)
+			}
+			argv[i] = b.String()
+		}
+
+		cmd := exec.Command(name, argv...)
+		cmd.Env = append(os.Environ(),
+			"CERT_FILE="+cert.File,
+			"KEY_FILE="+cert.KeyFile,
+			"DOMAINS="+strings.Join(cert.Domains, ","),
+		)
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		if err := cmd.Run(); err != nil {
+			if perr, ok := err.(*exec.ExitError); ok {
+				perr.Stderr = out.Bytes()
+			}
+			return fmt.Errorf("mkcert: hook %s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+// runHooks runs each of hooks in turn for cert, returning their errors.
+func runHooks(hooks []Hook, cert Cert) error {
+	var errs []error
+	for _, h := range hooks {
+		if err := h(cert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
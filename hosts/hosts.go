@@ -0,0 +1,226 @@
+// Package hosts maps the domains of local certificates to this machine in the
+// hosts file, so that they resolve without a DNS server. Nothing here runs
+// unless called: mkcert never edits the hosts file itself.
+//
+// The entries are kept in a block delimited by marker comments, which is the
+// only part of the file changed, so that removing them leaves the file as it
+// was found:
+//
+//	# BEGIN mkcert
+//	127.0.0.1 app.test api.test
+//	::1 app.test api.test
+//	# END mkcert
+package hosts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	beginMarker = "# BEGIN mkcert"
+	endMarker   = "# END mkcert"
+)
+
+// ErrElevation is returned when the hosts file can't be written without
+// administrator rights, and File.Sudo doesn't allow getting them.
+var ErrElevation = errors.New("hosts: writing the hosts file needs administrator rights")
+
+// File is a hosts file, with a block of entries managed by this package.
+type File struct {
+	// Path is the hosts file. It defaults to DefaultPath.
+	Path string
+	// Sudo has the file written with sudo if the process can't write it
+	// itself, which may prompt for a password on the terminal. On Windows,
+	// where there's no sudo, the process needs to be run as Administrator.
+	Sudo bool
+}
+
+// DefaultPath returns the system's hosts file: /etc/hosts, or
+// %SystemRoot%\System32\drivers\etc\hosts on Windows.
+func DefaultPath() string {
+	if runtime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+		return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+// Names returns the names mapped by the managed block, sorted.
+func (f File) Names() ([]string, error) {
+	b, err := ioutil.ReadFile(f.path())
+	if err != nil {
+		return nil, err
+	}
+	_, names, _, err := split(b)
+	return names, err
+}
+
+// Add maps the host names among domains to 127.0.0.1 and ::1, keeping those
+// mapped already. IP addresses, emails, URIs and wildcards, which the hosts
+// file can't express, are skipped, as is localhost. The file is only
+// written if the names change.
+func (f File) Add(domains ...string) error {
+	return f.update(func(names map[string]bool) {
+		for _, d := range hostNames(domains) {
+			names[d] = true
+		}
+	})
+}
+
+// Remove unmaps the host names among domains, or every name in the managed
+// block if none are given. The block is removed once it's empty.
+func (f File) Remove(domains ...string) error {
+	return f.update(func(names map[string]bool) {
+		if len(domains) == 0 {
+			for n := range names {
+				delete(names, n)
+			}
+		}
+		for _, d := range hostNames(domains) {
+			delete(names, d)
+		}
+	})
+}
+
+func (f File) path() string {
+	if f.Path == "" {
+		return DefaultPath()
+	}
+	return f.Path
+}
+
+// update rewrites the managed block with the names left by edit.
+func (f File) update(edit func(names map[string]bool)) error {
+	path := f.path()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	before, current, after, err := split(b)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(current))
+	for _, n := range current {
+		names[n] = true
+	}
+	edit(names)
+	var sorted []string
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+	if strings.Join(sorted, " ") == strings.Join(current, " ") {
+		return nil
+	}
+
+	nl := "\n"
+	if bytes.Contains(b, []byte("\r\n")) {
+		nl = "\r\n"
+	}
+	var buf bytes.Buffer
+	buf.Write(before)
+	if len(sorted) > 0 {
+		if buf.Len() > 0 && !bytes.HasSuffix(before, []byte("\n")) {
+			buf.WriteString(nl)
+		}
+		list := strings.Join(sorted, " ")
+		fmt.Fprintf(&buf, "%s%s127.0.0.1 %s%s::1 %s%s%s%s", beginMarker, nl, list, nl, list, nl, endMarker, nl)
+	}
+	buf.Write(after)
+	return f.write(path, buf.Bytes())
+}
+
+// write replaces the contents of the file at path in place, keeping its
+// owner and permissions, using sudo if allowed and needed.
+func (f File) write(path string, b []byte) error {
+	err := ioutil.WriteFile(path, b, 0644)
+	if !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	if !f.Sudo || runtime.GOOS == "windows" {
+		return fmt.Errorf("%w: %v", ErrElevation, err)
+	}
+	if _, lerr := exec.LookPath("sudo"); lerr != nil {
+		return fmt.Errorf("%w, and sudo isn't available: %v", ErrElevation, err)
+	}
+	cmd := exec.Command("sudo", "tee", path)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = ioutil.Discard
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hosts: sudo tee %s: %w", path, err)
+	}
+	return nil
+}
+
+// split returns the contents of a hosts file before and after the managed
+// block, and the names mapped in the block, sorted.
+func split(b []byte) (before []byte, names []string, after []byte, err error) {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	begin, end := -1, -1
+	for i, l := range lines {
+		switch string(bytes.TrimSpace(l)) {
+		case beginMarker:
+			if begin < 0 {
+				begin = i
+			}
+		case endMarker:
+			if begin >= 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+	if begin < 0 {
+		return b, nil, nil, nil
+	}
+	if end < 0 {
+		return nil, nil, nil, fmt.Errorf("hosts: %q without %q", beginMarker, endMarker)
+	}
+	seen := make(map[string]bool)
+	for _, l := range lines[begin+1 : end] {
+		fields := strings.Fields(string(l))
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, n := range fields[1:] {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	sort.Strings(names)
+	return bytes.Join(lines[:begin], nil), names, bytes.Join(lines[end+1:], nil), nil
+}
+
+// hostNames returns the names among domains which can be put in a hosts
+// file, lowercased without trailing dots.
+func hostNames(domains []string) []string {
+	var names []string
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimRight(strings.TrimSpace(d), "."))
+		switch {
+		case d == "" || d == "localhost":
+		case strings.ContainsAny(d, "@*/: \t"):
+		case net.ParseIP(d) != nil:
+		default:
+			names = append(names, d)
+		}
+	}
+	return names
+}
@@ -0,0 +1,71 @@
+package integrate
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/icio/mkcert"
+)
+
+// DockerRegistry has Docker trust the mkcert root CA for the registry at host,
+// such as "registry.test:5000", by writing it to Docker's certs.d directory.
+// It returns the path written.
+//
+// Docker Desktop and Colima read ~/.docker/certs.d, and need restarting to
+// see the change. The Docker daemon on Linux reads /etc/docker/certs.d,
+// which needs root to write to.
+func DockerRegistry(host string, opts ...mkcert.Opt) (string, error) {
+	if host == "" || strings.ContainsAny(host, `/\`) {
+		return "", fmt.Errorf("integrate: invalid registry host %q", host)
+	}
+	pem, err := rootCA(opts)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join("/etc", "docker", "certs.d", host)
+	if home, err := os.UserHomeDir(); err == nil && dockerInVM(home) {
+		dir = filepath.Join(home, ".docker", "certs.d", host)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "ca.crt")
+	return path, mkcert.WriteFileAtomic(path, pem, 0644)
+}
+
+// dockerInVM reports whether Docker runs in a VM (Docker Desktop or Colima)
+// rather than directly on this Linux machine.
+func dockerInVM(home string) bool {
+	if runtime.GOOS != "linux" {
+		return true
+	}
+	for _, dir := range []string{".colima", filepath.Join(".docker", "desktop")} {
+		if _, err := os.Stat(filepath.Join(home, dir)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Colima installs the mkcert root CA in the trust store of the Colima VM
+// running profile ("" for the default) and restarts its Docker daemon, so
+// that the daemon and containers sharing the VM's trust store trust
+// certificates issued by mkcert. It needs doing again if the VM is recreated.
+func Colima(profile string, opts ...mkcert.Opt) error {
+	pem, err := rootCA(opts)
+	if err != nil {
+		return err
+	}
+	args := []string{"ssh"}
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	// Colima's VM is Ubuntu, with update-ca-certificates.
+	args = append(args, "--", "sudo", "sh", "-c",
+		"cat > /usr/local/share/ca-certificates/mkcert.crt && update-ca-certificates && systemctl restart docker")
+	return runWithCA("colima ssh", exec.Command("colima", args...), pem)
+}
@@ -0,0 +1,24 @@
+// Package integrate configures other tools to trust the mkcert root CA, for
+// the places the system trust stores don't reach, such as containers and
+// virtual machines.
+package integrate
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/icio/mkcert"
+)
+
+// rootCA returns the PEM of the root CA mkcert uses with opts.
+func rootCA(opts []mkcert.Opt) ([]byte, error) {
+	trust, err := mkcert.TrustStatus(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if trust.CARoot == "" {
+		return nil, errors.New("integrate: mkcert didn't report its CAROOT")
+	}
+	return ioutil.ReadFile(filepath.Join(trust.CARoot, "rootCA.pem"))
+}
@@ -0,0 +1,94 @@
+package integrate
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/icio/mkcert"
+)
+
+// caInstallScript installs the CA read from stdin into a node's trust store
+// and restarts its container runtime. kind's nodes are Debian and minikube's
+// are Buildroot, both of which have update-ca-certificates.
+const caInstallScript = `set -e
+mkdir -p /usr/local/share/ca-certificates
+cat > /usr/local/share/ca-certificates/mkcert.crt
+update-ca-certificates
+if systemctl is-active -q containerd; then systemctl restart containerd; fi
+if systemctl is-active -q docker; then systemctl restart docker; fi
+if systemctl is-active -q crio; then systemctl restart crio; fi
+`
+
+// Kind installs the mkcert root CA into every node of the kind cluster
+// named cluster ("" for the default, "kind") and restarts their container
+// runtimes, so that images can be pulled from registries with certificates
+// issued by mkcert. Pods have their own trust stores, so need the CA given
+// to them separately, for example with export.Bundle.KubernetesSecret. It
+// needs doing again if the cluster is recreated.
+func Kind(cluster string, opts ...mkcert.Opt) error {
+	if cluster == "" {
+		cluster = "kind"
+	}
+	pem, err := rootCA(opts)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command("kind", "get", "nodes", "--name", cluster).Output()
+	if err != nil {
+		return commandError("kind get nodes", err)
+	}
+	nodes := strings.Fields(string(out))
+	if len(nodes) == 0 {
+		return fmt.Errorf("integrate: kind cluster %q has no nodes", cluster)
+	}
+	for _, node := range nodes {
+		// kind nodes are containers, running as root.
+		cmd := exec.Command("docker", "exec", "-i", node, "sh", "-c", caInstallScript)
+		if err := runWithCA("docker exec "+node, cmd, pem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Minikube installs the mkcert root CA into the minikube node of profile (""
+// for the default) and restarts its container runtime, as with Kind.
+// minikube also copies certificates in ~/.minikube/certs into the node when
+// it's started with --embed-certs, which survives it being recreated.
+func Minikube(profile string, opts ...mkcert.Opt) error {
+	pem, err := rootCA(opts)
+	if err != nil {
+		return err
+	}
+	args := []string{"ssh"}
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	args = append(args, "--", "sudo", "sh", "-c", shellQuote(caInstallScript))
+	return runWithCA("minikube ssh", exec.Command("minikube", args...), pem)
+}
+
+// runWithCA runs cmd with the CA as its stdin, describing it as name in
+// errors.
+func runWithCA(name string, cmd *exec.Cmd, pem []byte) error {
+	cmd.Stdin = bytes.NewReader(pem)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if perr, ok := err.(*exec.ExitError); ok {
+			perr.Stderr = out
+		}
+		return commandError(name, err)
+	}
+	return nil
+}
+
+func commandError(name string, err error) error {
+	return fmt.Errorf("integrate: %s: %w", name, err)
+}
+
+// shellQuote quotes s for a remote shell, as minikube ssh joins its
+// arguments into a single command line.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
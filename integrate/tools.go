@@ -0,0 +1,380 @@
+package integrate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/icio/mkcert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	bundleName     = "ca-bundle.pem"
+	trustStoreName = "truststore.p12"
+
+	// trustStorePassword is the password Java's own cacerts uses. The
+	// truststore holds no secrets.
+	trustStorePassword = "changeit"
+)
+
+// bundle writes the CA bundle for opts to CAROOT, returning its path. The
+// tools configured here each take a single CA bundle in place of the system
+// roots, so are given the system roots and the mkcert root CA together. Each
+// configuration is idempotent, and only undone if it still points at the
+// bundle.
+func bundle(opts []mkcert.Opt) (string, error) {
+	path, err := bundlePath(opts, bundleName)
+	if err != nil {
+		return "", err
+	}
+	_, err = mkcert.WriteBundle(path, opts...)
+	return path, err
+}
+
+// bundlePath returns the path to name in the CAROOT used with opts.
+func bundlePath(opts []mkcert.Opt, name string) (string, error) {
+	trust, err := mkcert.TrustStatus(opts...)
+	if err != nil {
+		return "", err
+	}
+	if trust.CARoot == "" {
+		return "", fmt.Errorf("integrate: mkcert didn't report its CAROOT")
+	}
+	return filepath.Join(trust.CARoot, name), nil
+}
+
+// Git has git trust the mkcert root CA by setting http.sslCAInfo in the
+// user's global git config.
+func Git(opts ...mkcert.Opt) error {
+	path, err := bundle(opts)
+	if err != nil {
+		return err
+	}
+	return gitConfig("--global", "http.sslCAInfo", path)
+}
+
+// UndoGit removes the http.sslCAInfo set by Git.
+func UndoGit(opts ...mkcert.Opt) error {
+	path, err := bundlePath(opts, bundleName)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command("git", "config", "--global", "--get", "http.sslCAInfo").Output()
+	if err != nil || strings.TrimSpace(string(out)) != path {
+		// Unset, or not ours.
+		return nil
+	}
+	return gitConfig("--global", "--unset", "http.sslCAInfo")
+}
+
+func gitConfig(args ...string) error {
+	cmd := exec.Command("git", append([]string{"config"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if perr, ok := err.(*exec.ExitError); ok {
+			perr.Stderr = out
+		}
+		return commandError("git config", err)
+	}
+	return nil
+}
+
+// Npm has npm trust the mkcert root CA by setting cafile in the user's
+// .npmrc, or $NPM_CONFIG_USERCONFIG.
+func Npm(opts ...mkcert.Opt) error {
+	path, err := bundle(opts)
+	if err != nil {
+		return err
+	}
+	return setINI(npmrc(), "", "cafile", path)
+}
+
+// UndoNpm removes the cafile set by Npm.
+func UndoNpm(opts ...mkcert.Opt) error {
+	path, err := bundlePath(opts, bundleName)
+	if err != nil {
+		return err
+	}
+	return unsetINI(npmrc(), "", "cafile", path)
+}
+
+func npmrc() string {
+	if f := os.Getenv("NPM_CONFIG_USERCONFIG"); f != "" {
+		return f
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".npmrc")
+}
+
+// Pip has pip trust the mkcert root CA by setting cert in the [global]
+// section of the user's pip.conf, or $PIP_CONFIG_FILE.
+func Pip(opts ...mkcert.Opt) error {
+	path, err := bundle(opts)
+	if err != nil {
+		return err
+	}
+	return setINI(pipConf(), "global", "cert", path)
+}
+
+// UndoPip removes the cert set by Pip.
+func UndoPip(opts ...mkcert.Opt) error {
+	path, err := bundlePath(opts, bundleName)
+	if err != nil {
+		return err
+	}
+	return unsetINI(pipConf(), "global", "cert", path)
+}
+
+func pipConf() string {
+	if f := os.Getenv("PIP_CONFIG_FILE"); f != "" {
+		return f
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(os.Getenv("APPDATA"), "pip", "pip.ini")
+	case "darwin":
+		home, _ := os.UserHomeDir()
+		legacy := filepath.Join(home, "Library", "Application Support", "pip", "pip.conf")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil || runtime.GOOS == "darwin" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "pip", "pip.conf")
+}
+
+// JavaToolOptions writes a PKCS#12 truststore of the system roots and the
+// mkcert root CA to CAROOT, and returns options, such as the current
+// $JAVA_TOOL_OPTIONS, with the javax.net.ssl.trustStore properties pointing
+// at it. Setting JAVA_TOOL_OPTIONS to the result has JVMs trust the CA
+// without modifying the JDK's cacerts, as mkcert -install does.
+func JavaToolOptions(options string, opts ...mkcert.Opt) (string, error) {
+	bundlePEM, err := bundle(opts)
+	if err != nil {
+		return "", err
+	}
+	path, err := bundlePath(opts, trustStoreName)
+	if err != nil {
+		return "", err
+	}
+	if err := writeTrustStore(path, bundlePEM); err != nil {
+		return "", err
+	}
+	flags := append(withoutTrustStore(options, ""),
+		"-Djavax.net.ssl.trustStore="+path,
+		"-Djavax.net.ssl.trustStoreType=PKCS12",
+		"-Djavax.net.ssl.trustStorePassword="+trustStorePassword,
+	)
+	return strings.Join(flags, " "), nil
+}
+
+// UndoJavaToolOptions returns options without the properties added by
+// JavaToolOptions.
+func UndoJavaToolOptions(options string, opts ...mkcert.Opt) (string, error) {
+	path, err := bundlePath(opts, trustStoreName)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(withoutTrustStore(options, path), " "), nil
+}
+
+// withoutTrustStore returns the fields of options other than the
+// javax.net.ssl.trustStore properties. If path is given, they're only
+// removed if the truststore is path.
+func withoutTrustStore(options, path string) []string {
+	fields := strings.Fields(options)
+	if path != "" {
+		ours := false
+		for _, f := range fields {
+			ours = ours || f == "-Djavax.net.ssl.trustStore="+path
+		}
+		if !ours {
+			return fields
+		}
+	}
+	var keep []string
+	for _, f := range fields {
+		if !strings.HasPrefix(f, "-Djavax.net.ssl.trustStore") {
+			keep = append(keep, f)
+		}
+	}
+	return keep
+}
+
+// writeTrustStore writes the certificates of the PEM bundle at bundlePEM to
+// a PKCS#12 truststore at path, if they've changed.
+func writeTrustStore(path, bundlePEM string) error {
+	rest, err := ioutil.ReadFile(bundlePEM)
+	if err != nil {
+		return err
+	}
+	var entries []pkcs12.TrustStoreEntry
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			// Leave out anything Go can't parse.
+			continue
+		}
+		// Java needs the aliases to be unique.
+		entries = append(entries, pkcs12.TrustStoreEntry{
+			Cert:         cert,
+			FriendlyName: fmt.Sprintf("%d %s", len(entries), cert.Subject),
+		})
+	}
+
+	// The encoding is salted, so compare the certificates.
+	if old, err := ioutil.ReadFile(path); err == nil {
+		if certs, err := pkcs12.DecodeTrustStore(old, trustStorePassword); err == nil && len(certs) == len(entries) {
+			same := true
+			for i, c := range certs {
+				same = same && bytes.Equal(c.Raw, entries[i].Cert.Raw)
+			}
+			if same {
+				return nil
+			}
+		}
+	}
+	pfx, err := pkcs12.Modern.EncodeTrustStoreEntries(entries, trustStorePassword)
+	if err != nil {
+		return fmt.Errorf("integrate: %w", err)
+	}
+	return mkcert.WriteFileAtomic(path, pfx, 0644)
+}
+
+// setINI sets key to value in section of the INI file at path, where the
+// section "" is the lines before any section header. The file is left as is
+// if key is already value.
+func setINI(path, section, key, value string) error {
+	lines, err := readLines(path)
+	if err != nil {
+		return err
+	}
+	line := key + " = " + value
+	if section == "" {
+		line = key + "=" + value
+	}
+
+	current, end := "", -1
+	for i, l := range lines {
+		if name, ok := iniSection(l); ok {
+			current = name
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if k, v, ok := iniKey(l); ok && k == key {
+			if v == value {
+				return nil
+			}
+			lines[i] = line
+			return writeLines(path, lines)
+		}
+		if strings.TrimSpace(l) != "" {
+			end = i
+		}
+	}
+	switch {
+	case end >= 0:
+		lines = append(lines[:end+1], append([]string{line}, lines[end+1:]...)...)
+	case section == "":
+		lines = append([]string{line}, lines...)
+	default:
+		if !containsSection(lines, section) {
+			lines = append(lines, "["+section+"]")
+		}
+		for i, l := range lines {
+			if name, ok := iniSection(l); ok && name == section {
+				lines = append(lines[:i+1], append([]string{line}, lines[i+1:]...)...)
+				break
+			}
+		}
+	}
+	return writeLines(path, lines)
+}
+
+// unsetINI removes key from section of the INI file at path if it's value.
+func unsetINI(path, section, key, value string) error {
+	lines, err := readLines(path)
+	if err != nil || lines == nil {
+		return err
+	}
+	current := ""
+	for i, l := range lines {
+		if name, ok := iniSection(l); ok {
+			current = name
+			continue
+		}
+		if k, v, ok := iniKey(l); ok && current == section && k == key && v == value {
+			return writeLines(path, append(lines[:i], lines[i+1:]...))
+		}
+	}
+	return nil
+}
+
+func iniSection(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+func iniKey(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	eq := strings.Index(line, "=")
+	if eq < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:]), true
+}
+
+func containsSection(lines []string, section string) bool {
+	for _, l := range lines {
+		if name, ok := iniSection(l); ok && name == section {
+			return true
+		}
+	}
+	return false
+}
+
+// readLines returns the lines of the file at path, or none if it doesn't
+// exist.
+func readLines(path string) ([]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"), nil
+}
+
+func writeLines(path string, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return mkcert.WriteFileAtomic(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
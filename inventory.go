@@ -0,0 +1,179 @@
+package mkcert
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Issued is a certificate recorded in the audit log, with where it stands
+// now.
+type Issued struct {
+	AuditRecord
+	// Current reports whether CertFile still holds the certificate, rather
+	// than having been removed or replaced by one issued since.
+	Current bool `json:"current"`
+	// Revoked is when the certificate was revoked with Revoke, or nil if it
+	// wasn't.
+	Revoked *time.Time `json:"revoked,omitempty"`
+}
+
+// Expired reports whether the certificate has expired.
+func (i Issued) Expired() bool {
+	return time.Now().After(i.NotAfter)
+}
+
+// List returns the certificates recorded by Audit, oldest first, so that
+// what the CA has issued, and where, can be answered. The log read is the
+// one Audit would write to for opts, such as with CARoot or AuditFile. A
+// missing log lists nothing.
+func List(opts ...Opt) ([]Issued, error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	path, err := auditPath(p)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := ReadAudit(path)
+	if err != nil {
+		return nil, err
+	}
+	issued := make([]Issued, len(recs))
+	revoked := make(map[string]map[string]time.Time)
+	for i, rec := range recs {
+		r, ok := revoked[rec.CARoot]
+		if !ok {
+			if r, err = readRevoked(rec.CARoot); err != nil {
+				return nil, err
+			}
+			revoked[rec.CARoot] = r
+		}
+		issued[i] = Issued{AuditRecord: rec, Current: holds(rec.CertFile, rec.Serial)}
+		if t, ok := r[rec.Serial]; ok {
+			issued[i].Revoked = &t
+		}
+	}
+	return issued, nil
+}
+
+// Find returns the certificates listed by List which cover domain, either
+// by name or by a wildcard.
+func Find(domain string, opts ...Opt) ([]Issued, error) {
+	issued, err := List(opts...)
+	if err != nil {
+		return nil, err
+	}
+	norm := normalizeDomains([]string{domain})
+	if len(norm) == 0 {
+		return nil, nil
+	}
+	var found []Issued
+	for _, i := range issued {
+		for _, d := range i.Domains {
+			if coversDomain(d, norm[0]) {
+				found = append(found, i)
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// Prune removes the files of the certificates listed by List which are
+// still current, or only those which have expired if expiredOnly is set,
+// returning the certificates removed. Files which have been replaced by
+// another certificate are left alone. The audit log keeps the records of the
+// certificates removed, as it's a history of what was issued, and they're
+// listed afterwards as no longer current.
+func Prune(expiredOnly bool, opts ...Opt) ([]Issued, error) {
+	issued, err := List(opts...)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []Issued
+	for _, i := range issued {
+		if !i.Current || (expiredOnly && !i.Expired()) {
+			continue
+		}
+		for _, f := range []string{i.CertFile, i.KeyFile} {
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				return pruned, fmt.Errorf("mkcert: prune: %w", err)
+			}
+		}
+		i.Current = false
+		pruned = append(pruned, i)
+	}
+	return pruned, nil
+}
+
+// ReadAudit returns the records of the audit log at path, oldest first. A
+// missing log has no records.
+func ReadAudit(path string) ([]AuditRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("mkcert: audit log: %w", err)
+	}
+	defer f.Close()
+	var recs []AuditRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, fmt.Errorf("mkcert: audit log: %s:%d: %w", path, n, err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("mkcert: audit log: %w", err)
+	}
+	return recs, nil
+}
+
+// auditPath returns the audit log Audit writes to for p.
+func auditPath(p params) (string, error) {
+	if p.AuditFile != "" {
+		return p.AuditFile, nil
+	}
+	caroot := p.CARoot
+	if caroot == "" {
+		var err error
+		if caroot, err = findCARoot(p); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(caroot, AuditFileName), nil
+}
+
+// holds reports whether the certificate in path has the serial number, in
+// hex.
+func holds(path, serial string) bool {
+	leaf, err := readCert(path)
+	return err == nil && fmt.Sprintf("%x", leaf.SerialNumber) == serial
+}
+
+// coversDomain reports whether the name in a certificate covers domain, as a
+// normalized name, either being it or a wildcard matching its first label.
+func coversDomain(name, domain string) bool {
+	name = strings.ToLower(name)
+	if name == domain {
+		return true
+	}
+	if !strings.HasPrefix(name, "*.") {
+		return false
+	}
+	i := strings.IndexByte(domain, '.')
+	return i > 0 && domain[i:] == name[1:]
+}
@@ -0,0 +1,72 @@
+package mkcert
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// javaHome returns the JDK whose cacerts mkcert checks for the java store:
+// the one in JAVA_HOME or, as mkcert ignores Java without it, the one
+// providing the java command. It's blank if there's no JDK.
+func javaHome(p params) string {
+	if home := p.getenv("JAVA_HOME"); home != "" {
+		return home
+	}
+
+	var home string
+	if runtime.GOOS == "darwin" {
+		// /usr/bin/java is a stub which finds the JDK the same way.
+		out, err := exec.Command("/usr/libexec/java_home").Output()
+		if err != nil {
+			return ""
+		}
+		home = string(bytes.TrimSpace(out))
+	} else {
+		java, err := exec.LookPath("java")
+		if err != nil {
+			return ""
+		}
+		if java, err = filepath.EvalSymlinks(java); err != nil {
+			return ""
+		}
+		home = filepath.Dir(filepath.Dir(java))
+	}
+
+	keytool := filepath.Join(home, "bin", "keytool")
+	if runtime.GOOS == "windows" {
+		keytool += ".exe"
+	}
+	if _, err := os.Stat(keytool); err != nil {
+		return ""
+	}
+	for _, cacerts := range []string{
+		filepath.Join(home, "lib", "security", "cacerts"),
+		filepath.Join(home, "jre", "lib", "security", "cacerts"),
+	} {
+		if _, err := os.Stat(cacerts); err == nil {
+			return home
+		}
+	}
+	return ""
+}
+
+// javaEnv returns the JAVA_HOME to set for mkcert to check the java store,
+// if it isn't set already. It's only used to check trust: Install leaves it
+// to the caller to choose the JDK to write to.
+func javaEnv(p params, args []string) []string {
+	for _, a := range args {
+		if a == "-install" || a == "-uninstall" {
+			return nil
+		}
+	}
+	if p.getenv("JAVA_HOME") != "" {
+		return nil
+	}
+	if home := javaHome(p); home != "" {
+		return []string{"JAVA_HOME=" + home}
+	}
+	return nil
+}
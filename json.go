@@ -0,0 +1,39 @@
+package mkcert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// certJSON is the JSON form of Cert, without its methods.
+type certJSON Cert
+
+// MarshalJSON encodes c along with the expiry and SHA-256 fingerprint of the
+// certificate in File, when it can be read.
+func (c Cert) MarshalJSON() ([]byte, error) {
+	v := struct {
+		certJSON
+		NotAfter    *time.Time `json:"not_after,omitempty"`
+		Fingerprint string     `json:"fingerprint,omitempty"`
+	}{certJSON: certJSON(c)}
+	if leaf, err := readCert(c.File); err == nil {
+		sum := sha256.Sum256(leaf.Raw)
+		v.NotAfter = &leaf.NotAfter
+		v.Fingerprint = hex.EncodeToString(sum[:])
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a Cert written by MarshalJSON. The expiry and
+// fingerprint are ignored, as they're read from File when needed.
+func (c *Cert) UnmarshalJSON(b []byte) error {
+	var v certJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*c = Cert(v)
+	c.tempDir = ""
+	return nil
+}
@@ -0,0 +1,59 @@
+package mkcert
+
+import "strings"
+
+// macOS keychains the CA can be installed in.
+const (
+	// KeychainSystem is the System keychain, trusted by every user, which is
+	// where mkcert installs the CA. It needs administrator rights.
+	KeychainSystem = "system"
+	// KeychainLogin is the user's login keychain, trusted only by them,
+	// which can be written to where the System keychain is locked down.
+	KeychainLogin = "login"
+)
+
+// Keychain chooses the macOS keychain Install puts the CA in, either
+// KeychainSystem or KeychainLogin. mkcert itself only supports
+// KeychainSystem, so with KeychainLogin the system store is installed into
+// using the security command instead of mkcert. It's ignored on other
+// platforms.
+func Keychain(name string) Opt {
+	return func(p *params) { p.Keychain = name }
+}
+
+// withoutSystemStore returns p with the system store removed from the stores
+// mkcert uses.
+func withoutSystemStore(p params) params {
+	stores := p.getenv("TRUST_STORES")
+	if stores == "" {
+		stores = StoreNSS + "," + StoreJava
+	}
+	var kept []string
+	for _, s := range strings.Split(stores, ",") {
+		if s != StoreSystem {
+			kept = append(kept, s)
+		}
+	}
+	// mkcert treats an empty TRUST_STORES as all of them, but ignores stores
+	// it doesn't know.
+	if len(kept) == 0 {
+		kept = []string{"none"}
+	}
+	p.trustStores = strings.Join(kept, ",")
+	return p
+}
+
+// storeEnabled reports whether mkcert will use store, according to
+// TRUST_STORES.
+func storeEnabled(p params, store string) bool {
+	stores := p.getenv("TRUST_STORES")
+	if stores == "" {
+		return true
+	}
+	for _, s := range strings.Split(stores, ",") {
+		if s == store {
+			return true
+		}
+	}
+	return false
+}
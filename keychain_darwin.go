@@ -0,0 +1,60 @@
+package mkcert
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// installKeychain adds the root CA to the login keychain's trust settings
+// when that's the keychain chosen, returning p with the system store left
+// out for mkcert. Otherwise it returns p unchanged for mkcert to install the
+// CA in the System keychain.
+func installKeychain(p params) (params, error) {
+	if p.Keychain != KeychainLogin || !storeEnabled(p, StoreSystem) {
+		return p, nil
+	}
+	caroot, err := findCARoot(p)
+	if err != nil {
+		return p, err
+	}
+	// mkcert creates the CA if it's missing.
+	if _, err := run(p); err != nil {
+		return p, err
+	}
+	cmd := exec.Command("security", "add-trusted-cert", "-r", "trustRoot", "-k", loginKeychain(), filepath.Join(caroot, "rootCA.pem"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if perr, ok := err.(*exec.ExitError); ok {
+			perr.Stderr = out
+		}
+		return p, fmt.Errorf("mkcert: security add-trusted-cert: %w", err)
+	}
+	return withoutSystemStore(p), nil
+}
+
+// findKeychain returns the keychain containing the root CA in caroot.
+func findKeychain(caroot string) string {
+	root, err := readCert(filepath.Join(caroot, "rootCA.pem"))
+	if err != nil {
+		return ""
+	}
+	hash := []byte(fmt.Sprintf("SHA-1 hash: %X\n", sha1.Sum(root.Raw)))
+	for _, k := range []struct{ name, path string }{
+		{KeychainSystem, "/Library/Keychains/System.keychain"},
+		{KeychainLogin, loginKeychain()},
+	} {
+		out, err := exec.Command("security", "find-certificate", "-a", "-Z", k.path).Output()
+		if err == nil && bytes.Contains(out, hash) {
+			return k.name
+		}
+	}
+	return ""
+}
+
+func loginKeychain() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "Keychains", "login.keychain-db")
+}
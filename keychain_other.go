@@ -0,0 +1,9 @@
+//go:build !darwin
+
+package mkcert
+
+// installKeychain returns p unchanged, as there are only keychains on macOS.
+func installKeychain(p params) (params, error) { return p, nil }
+
+// findKeychain returns "", as there are only keychains on macOS.
+func findKeychain(caroot string) string { return "" }
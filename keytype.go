@@ -0,0 +1,15 @@
+package mkcert
+
+import "crypto/x509"
+
+// ECDSA has mkcert generate an ECDSA P-256 key for the certificate, with
+// mkcert -ecdsa, rather than an RSA key. Reuse only returns certificates with
+// the chosen type of key.
+func ECDSA(ecdsa bool) Opt {
+	return func(p *params) { p.ECDSA = ecdsa }
+}
+
+// isECDSA reports whether cert has an ECDSA key.
+func isECDSA(cert *x509.Certificate) bool {
+	return cert.PublicKeyAlgorithm == x509.ECDSA
+}
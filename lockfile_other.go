@@ -0,0 +1,35 @@
+//go:build !unix
+
+package mkcert
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// staleLock is how old a lock file can be before it's assumed to have been
+// left behind by a process which exited without removing it.
+const staleLock = time.Minute
+
+// lockFile takes an exclusive lock on the file at path, by creating it,
+// waiting for other processes to remove it. The lock is released by unlock.
+// Without flock, a lock left behind by a crashed process is broken once it's
+// older than staleLock.
+func lockFile(path string) (unlock func(), err error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,546 @@
+package mkcert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Manager issues certificates with mkcert as they're needed and keeps them in
+// memory, for use in a tls.Config. Certificates are reissued in the
+// background once they're within RenewBefore of expiry, and swapped in for
+// new handshakes once they're ready, so that renewal never interrupts
+// serving.
+//
+// The zero value is ready to use, issuing certificates for any host into
+// temporary directories which are removed once the certificate is loaded.
+type Manager struct {
+	// Opts are given to Exec for every certificate, such as Directory and
+	// Reuse to keep the certificates between runs, or RequireTrusted. CertFile
+	// and KeyFile shouldn't be used, as every certificate would be written to
+	// the same files.
+	Opts []Opt
+
+	// HostPolicy is consulted by GetCertificate before issuing a certificate
+	// for a host, which is refused if it returns an error. When nil, any host
+	// is allowed.
+	HostPolicy func(ctx context.Context, host string) error
+
+	// WarmHosts are issued certificates in the background as soon as the
+	// Manager is first used, by TLSConfig, GetCertificate or Get, so that
+	// the first handshakes for them needn't wait for mkcert. Other hosts are
+	// still issued on demand. The hosts aren't checked with HostPolicy.
+	WarmHosts []string
+
+	// OnRenew hooks are run after a certificate is reissued because the
+	// previous one was close to expiry, or by Reload, before it's used. If
+	// the certificates are kept in temporary directories, which is the
+	// default without a Directory, the files only exist while the hooks run.
+	OnRenew []Hook
+
+	// OnExpiring is called once for each certificate m uses which expires
+	// within WarnBefore, such as because renewing it keeps failing, for
+	// showing the user a warning. It's called by Get, so shouldn't block.
+	// When nil, the warnings are logged to ErrorLog.
+	OnExpiring func(domains []string, notAfter time.Time)
+
+	// WarnBefore is how long before expiry OnExpiring is called. Defaults
+	// to three days.
+	WarnBefore time.Duration
+
+	// RejectTTL is how long GetCertificate remembers hosts refused by
+	// HostPolicy and invalid server names, refusing them again without
+	// asking HostPolicy, so that scanners and misconfigured clients
+	// repeating their handshakes are cheap to turn away. Defaults to a
+	// minute. Negative durations disable it.
+	RejectTTL time.Duration
+
+	// ErrorLog logs errors from OnRenew hooks and background renewals, and
+	// expiry warnings. When nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+
+	warm     sync.Once
+	mu       sync.Mutex
+	certs    map[string]*managedCert
+	rejected map[string]rejection
+	bg       sync.WaitGroup // Background issuing.
+
+	rejections atomic.Int64
+}
+
+// rejection is a host refused by GetCertificate.
+type rejection struct {
+	err     error
+	expires time.Time
+}
+
+// maxRejected bounds the hosts remembered for RejectTTL, for when a scanner
+// tries lots of them.
+const maxRejected = 10000
+
+// managedCert is a certificate issued or being issued by a Manager.
+type managedCert struct {
+	done     chan struct{}
+	cert     *tls.Certificate
+	info     Cert
+	err      error
+	renewing bool // Guarded by Manager.mu.
+	warned   bool // Guarded by Manager.mu.
+}
+
+// TLSConfig returns a tls.Config which gets its certificates from m.
+func (m *Manager) TLSConfig() *tls.Config {
+	m.warmUp()
+	return &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// GetCertificate returns a certificate for the server name the client asked
+// for, issuing it if needed. Clients connecting by IP address don't send a
+// server name, and are given a certificate for the IP address they connected
+// to. It is intended for use as tls.Config.GetCertificate.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if host == "" {
+		host = localIP(hello.Conn)
+	}
+	if host == "" {
+		m.rejections.Add(1)
+		return nil, errors.New("mkcert: missing server name")
+	}
+	if err := m.rejectedErr(host); err != nil {
+		m.rejections.Add(1)
+		return nil, err
+	}
+	var err error
+	if !validHost(host) {
+		err = fmt.Errorf("mkcert: invalid server name %q", host)
+	} else if m.HostPolicy != nil {
+		err = m.HostPolicy(hello.Context(), host)
+	}
+	if err != nil {
+		m.rejections.Add(1)
+		if ctx := hello.Context(); ctx == nil || ctx.Err() == nil {
+			// Don't remember the handshake giving up as a refusal.
+			m.reject(host, err)
+		}
+		return nil, err
+	}
+	return m.Get(host)
+}
+
+// Rejections returns the number of handshakes GetCertificate has refused
+// because of their server name, whether missing, invalid, refused by
+// HostPolicy, or remembered for RejectTTL.
+func (m *Manager) Rejections() int64 {
+	return m.rejections.Load()
+}
+
+// rejectedErr returns the error host was refused with within RejectTTL.
+func (m *Manager) rejectedErr(host string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rejected[host]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(r.expires) {
+		delete(m.rejected, host)
+		return nil
+	}
+	return r.err
+}
+
+// reject remembers that host was refused with err, for RejectTTL.
+func (m *Manager) reject(host string, err error) {
+	ttl := m.RejectTTL
+	if ttl == 0 {
+		ttl = time.Minute
+	} else if ttl < 0 {
+		return
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.rejected) >= maxRejected {
+		for h, r := range m.rejected {
+			if now.After(r.expires) {
+				delete(m.rejected, h)
+			}
+		}
+		if len(m.rejected) >= maxRejected {
+			m.rejected = nil
+		}
+	}
+	if m.rejected == nil {
+		m.rejected = make(map[string]rejection)
+	}
+	m.rejected[host] = rejection{err: err, expires: now.Add(ttl)}
+}
+
+// validHost reports whether host, a lowercased server name or IP address,
+// is one mkcert could issue a certificate for.
+func validHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// localIP returns the local IP address of conn, or "" if it has none.
+func localIP(conn net.Conn) string {
+	if conn == nil {
+		return ""
+	}
+	var ip net.IP
+	switch addr := conn.LocalAddr().(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return ""
+		}
+		ip = net.ParseIP(host)
+	}
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return ip.String()
+}
+
+// Get returns a certificate covering domains, issuing it if there isn't one
+// already or it's close to expiry. Concurrent calls for the same domains wait
+// for the same certificate.
+func (m *Manager) Get(domains ...string) (*tls.Certificate, error) {
+	m.warmUp()
+	domains = normalizeDomains(domains)
+	if len(domains) == 0 {
+		return nil, ErrNoDomains
+	}
+	key := strings.Join(domains, " ")
+
+	renew := false
+	for {
+		m.mu.Lock()
+		c, ok := m.certs[key]
+		if !ok {
+			c = &managedCert{done: make(chan struct{})}
+			if m.certs == nil {
+				m.certs = make(map[string]*managedCert)
+			}
+			m.certs[key] = c
+			m.mu.Unlock()
+
+			c.cert, c.info, c.err = m.issue(domains, renew)
+			close(c.done)
+			if c.err != nil {
+				// Try again next time rather than remembering the failure.
+				m.forget(key, c)
+				return nil, c.err
+			}
+			m.warnExpiring(domains, c)
+			return c.cert, nil
+		}
+		m.mu.Unlock()
+
+		<-c.done
+		if c.err != nil {
+			return nil, c.err
+		}
+		m.warnExpiring(domains, c)
+		left := time.Until(c.cert.Leaf.NotAfter)
+		if left >= m.renewBefore() {
+			return c.cert, nil
+		}
+		if left > 0 {
+			// Keep using it until its replacement is ready.
+			m.renew(key, domains, c)
+			return c.cert, nil
+		}
+		m.forget(key, c)
+		renew = true
+	}
+}
+
+// Cert describes the certificate m holds covering domains, as Exec returned
+// it, without issuing one. File and KeyFile are blank if it was issued into a
+// temporary directory, which is removed once the certificate is loaded. It
+// returns false if m doesn't hold the certificate, such as before Get.
+func (m *Manager) Cert(domains ...string) (Cert, bool) {
+	key := strings.Join(normalizeDomains(domains), " ")
+	m.mu.Lock()
+	c, ok := m.certs[key]
+	m.mu.Unlock()
+	if !ok {
+		return Cert{}, false
+	}
+	select {
+	case <-c.done:
+	default:
+		return Cert{}, false
+	}
+	if c.err != nil {
+		return Cert{}, false
+	}
+	info := c.info
+	info.Domains = append([]string(nil), info.Domains...)
+	return info, true
+}
+
+// Prewarm issues the certificates for domainSets ahead of time, concurrently,
+// so that later calls to Get and GetCertificate needn't wait for mkcert. It
+// returns once they've all been issued or ctx is done, in which case the
+// issuing continues in the background.
+func (m *Manager) Prewarm(ctx context.Context, domainSets ...[]string) error {
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	errc := make(chan error, len(domainSets))
+	for _, domains := range domainSets {
+		m.bg.Add(1)
+		go func(domains []string) {
+			defer m.bg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+			_, err := m.Get(domains...)
+			<-sem
+			errc <- err
+		}(domains)
+	}
+
+	var errs []error
+	for range domainSets {
+		select {
+		case err := <-errc:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reload issues each of the certificates m holds again, such as after the
+// CA changes, or on SIGHUP. With Reuse, that re-reads the files if they're
+// still valid. Certificates are replaced as they're issued, with connections
+// already using the old ones unaffected, and any which fail to issue are kept.
+// The OnRenew hooks are run for each certificate reissued.
+func (m *Manager) Reload() error {
+	m.mu.Lock()
+	held := make(map[string]*managedCert, len(m.certs))
+	for key, c := range m.certs {
+		held[key] = c
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for key, old := range held {
+		if <-old.done; old.err != nil {
+			continue
+		}
+		c := &managedCert{done: make(chan struct{})}
+		c.cert, c.info, c.err = m.issue(strings.Split(key, " "), true)
+		close(c.done)
+		if c.err != nil {
+			errs = append(errs, c.err)
+			continue
+		}
+		m.replace(key, old, c)
+	}
+	return errors.Join(errs...)
+}
+
+// warmUp issues the WarmHosts in the background, the first time it's called.
+func (m *Manager) warmUp() {
+	m.warm.Do(func() {
+		if len(m.WarmHosts) == 0 {
+			return
+		}
+		sets := make([][]string, len(m.WarmHosts))
+		for i, host := range m.WarmHosts {
+			sets[i] = []string{host}
+		}
+		m.bg.Add(1)
+		go func() {
+			defer m.bg.Done()
+			if err := m.Prewarm(context.Background(), sets...); err != nil {
+				m.logf("warming up: %v", err)
+			}
+		}()
+	})
+}
+
+// Close waits for certificates being issued in the background to finish, and
+// then forgets all the certificates and rejected hosts. The Manager can still be used
+// afterwards, but will need to issue them again.
+func (m *Manager) Close() error {
+	m.bg.Wait()
+	m.mu.Lock()
+	m.certs = nil
+	m.rejected = nil
+	m.mu.Unlock()
+	return nil
+}
+
+// renew reissues c in the background, unless it's already being renewed,
+// replacing it once the new certificate is ready. If renewal fails, it's
+// tried again the next time c is used.
+func (m *Manager) renew(key string, domains []string, c *managedCert) {
+	m.mu.Lock()
+	if c.renewing || m.certs[key] != c {
+		m.mu.Unlock()
+		return
+	}
+	c.renewing = true
+	m.bg.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.bg.Done()
+		nc := &managedCert{done: make(chan struct{})}
+		nc.cert, nc.info, nc.err = m.issue(domains, true)
+		close(nc.done)
+		if nc.err != nil {
+			m.logf("renewing %s: %v", key, nc.err)
+			m.mu.Lock()
+			c.renewing = false
+			m.mu.Unlock()
+			return
+		}
+		m.replace(key, c, nc)
+	}()
+}
+
+// warnExpiring calls OnExpiring for c if it's expiring within WarnBefore and
+// hasn't been warned about already.
+func (m *Manager) warnExpiring(domains []string, c *managedCert) {
+	before := m.WarnBefore
+	if before == 0 {
+		before = 3 * 24 * time.Hour
+	}
+	notAfter := c.cert.Leaf.NotAfter
+	if time.Until(notAfter) >= before {
+		return
+	}
+	m.mu.Lock()
+	warned := c.warned
+	c.warned = true
+	m.mu.Unlock()
+	if warned {
+		return
+	}
+
+	if m.OnExpiring != nil {
+		m.OnExpiring(domains, notAfter)
+		return
+	}
+	m.logf("certificate for %s expires in %s, at %s", strings.Join(domains, ", "), time.Until(notAfter).Round(time.Minute), notAfter.Format(time.RFC3339))
+}
+
+// replace swaps c for old in the cache, unless old has already been
+// replaced.
+func (m *Manager) replace(key string, old, c *managedCert) {
+	m.mu.Lock()
+	if m.certs[key] == old {
+		m.certs[key] = c
+	}
+	m.mu.Unlock()
+}
+
+// forget removes c from the cache, unless it's already been replaced.
+func (m *Manager) forget(key string, c *managedCert) {
+	m.mu.Lock()
+	if m.certs[key] == c {
+		delete(m.certs, key)
+	}
+	m.mu.Unlock()
+}
+
+// issue invokes mkcert for a certificate covering domains, running the
+// OnRenew hooks if it's a renewal.
+func (m *Manager) issue(domains []string, renew bool) (*tls.Certificate, Cert, error) {
+	opts := append([]Opt(nil), m.Opts...)
+	if p := m.params(); p.Directory == "" && !p.TempDir {
+		opts = append(opts, TempDir())
+	}
+	cert, err := Exec(append(opts, Domains(domains...))...)
+	defer cert.Cleanup()
+	if err != nil {
+		return nil, Cert{}, err
+	}
+	pair, err := tls.LoadX509KeyPair(cert.File, cert.KeyFile)
+	if err != nil {
+		return nil, Cert{}, err
+	}
+	// Only Go 1.23 and later fill in Leaf, and then only for main modules
+	// declaring it, so it's parsed here whatever the toolchain.
+	if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+		return nil, Cert{}, err
+	}
+	if renew {
+		if err := runHooks(m.OnRenew, cert); err != nil {
+			m.logf("%v", err)
+		}
+	}
+	info := cert
+	if info.tempDir != "" {
+		info.File, info.KeyFile, info.tempDir = "", "", ""
+	}
+	return &pair, info, nil
+}
+
+func (m *Manager) logf(format string, args ...interface{}) {
+	if m.ErrorLog != nil {
+		m.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// renewBefore is how long before expiry certificates are reissued.
+func (m *Manager) renewBefore() time.Duration {
+	if d := m.params().RenewBefore; d != 0 {
+		return d
+	}
+	return reuseMargin
+}
+
+func (m *Manager) params() params {
+	var p params
+	for _, o := range m.Opts {
+		o(&p)
+	}
+	return p
+}
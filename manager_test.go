@@ -0,0 +1,109 @@
+package mkcert_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"testing"
+
+	"github.com/icio/mkcert"
+	"github.com/icio/mkcert/mkcerttest"
+)
+
+// TestManagerReloadKeepsConnections checks that connections open when the
+// Manager's certificates are reissued keep working with the old certificate,
+// while new handshakes get the new one.
+func TestManagerReloadKeepsConnections(t *testing.T) {
+	opts := []mkcert.Opt{mkcerttest.UseFake(t)}
+	opts = append(opts, mkcerttest.TempCA(t, opts...))
+	m := mkcerttest.Manager(t, opts...)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", m.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	url := "https://localhost:" + port + "/"
+
+	newClient := func() *http.Client {
+		tr := &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: mkcerttest.RootCAs(t, opts...)},
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, ln.Addr().String())
+			},
+		}
+		t.Cleanup(tr.CloseIdleConnections)
+		return &http.Client{Transport: tr}
+	}
+	// get requests url with c, returning the serial of the certificate it was
+	// served with and whether the connection was reused.
+	get := func(c *http.Client) (serial string, reused bool) {
+		t.Helper()
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+		}))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if body, err := ioutil.ReadAll(resp.Body); err != nil || string(body) != "ok" {
+			t.Fatalf("got %q, %v; want ok", body, err)
+		}
+		return resp.TLS.PeerCertificates[0].SerialNumber.String(), reused
+	}
+
+	open := newClient()
+	before, _ := get(open)
+
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	serial, reused := get(open)
+	if !reused {
+		t.Error("the open connection wasn't reused after Reload")
+	}
+	if serial != before {
+		t.Errorf("open connection served with serial %s after Reload, want the old %s", serial, before)
+	}
+	if after, _ := get(newClient()); after == before {
+		t.Errorf("new connection served with the old certificate, serial %s", before)
+	}
+}
+
+// TestManagerParsesLeaf checks that the Manager's certificates have their
+// Leaf, which tls.LoadX509KeyPair leaves nil before Go 1.23, and that it's
+// the certificate served.
+func TestManagerParsesLeaf(t *testing.T) {
+	opts := []mkcert.Opt{mkcerttest.UseFake(t)}
+	opts = append(opts, mkcerttest.TempCA(t, opts...))
+	m := mkcerttest.Manager(t, opts...)
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf == nil {
+		t.Fatal("certificate has no Leaf")
+	}
+	if !bytes.Equal(cert.Leaf.Raw, cert.Certificate[0]) {
+		t.Error("Leaf isn't the certificate served")
+	}
+	if got := cert.Leaf.DNSNames; len(got) == 0 || got[0] != "localhost" {
+		t.Errorf("Leaf covers %q, want localhost", got)
+	}
+}
@@ -1,19 +1,25 @@
 // Package mkcert wraps the mkcert CLI (https://github.com/FiloSottile/mkcert)
 // to provide a programmatic means of generating certificates for local
-// services. mkcert output is parsed to find the certificate file locations and
-// whether the CA is trusted.
+// services. mkcert output is parsed, using package mkcertout, to find the
+// certificate file locations and whether the CA is trusted.
 //
 // The CA used and trust stores considered are controlled using the CAROOT and
-// TRUST_STORES envvars mkcert wants. See mkcert -help for more.
+// TRUST_STORES envvars mkcert wants, or the CARoot option. OpenCA gives a
+// handle on a CA in a directory of its own, for issuing from several CAs in
+// one process. See mkcert -help for more.
 package mkcert
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"time"
+
+	"github.com/icio/mkcert/mkcertout"
 )
 
 var (
@@ -26,130 +32,324 @@ var (
 // trust info.
 type Cert struct {
 	// CARoot is the mkcert directory containing its root CA.
-	CARoot string
+	CARoot string `json:"caroot"`
 	// Trusted indicates that the root CA is installed in all of the system
 	// trust stores. If not, the user will need to run 'mkcert -install' to
 	// ensure their browser trusts the certificate we have generated.
-	Trusted bool
-	// Domains the certificate covers.
-	Domains []string
+	Trusted bool `json:"trusted"`
+	// Untrusted lists the trust stores missing the root CA, such as
+	// StoreNSS.
+	Untrusted []string `json:"untrusted,omitempty"`
+	// Domains the certificate covers, normalized as described by Domains.
+	Domains []string `json:"domains"`
 	// File is the filepath of the certificate file.
-	File string
+	File string `json:"file"`
 	// KeyFile is the filepath of the private key.
-	KeyFile string
+	KeyFile string `json:"key_file"`
+	// Usages are the extended key usages of the certificate, such as
+	// UsageServerAuth and UsageClientAuth.
+	Usages []string `json:"usages,omitempty"`
+
+	// tempDir is the directory created by the TempDir option.
+	tempDir string
+	// chain caches Chain.
+	chain *chainCache
 }
 
 // Exec invokes mkcert to acquire a certificate. A certificate for localhost
 // can be requested using:
 //
 //     mkcert.Exec(Domains("localhost", "::1", "127.0.0.1"))
+//
+// Options which contradict each other are reported as an *OptionError.
 func Exec(opts ...Opt) (Cert, error) {
 	var p params
 	for _, o := range opts {
 		o(&p)
 	}
-	if len(p.domains) == 0 {
+	if p.result != nil {
+		*p.result = ExecResult{}
+		start := time.Now()
+		defer func() { p.result.Duration = time.Since(start) }()
+	}
+	cert, err := execCert(p)
+	if p.result != nil {
+		p.result.Cert, p.result.Err = cert, err
+	}
+	return cert, err
+}
+
+func execCert(p params) (Cert, error) {
+	p.Domains = p.normalizedDomains()
+	if len(p.Domains) == 0 {
 		return Cert{}, ErrNoDomains
 	}
+	if err := p.validate(); err != nil {
+		return Cert{}, err
+	}
 
-	// Ask mkcert to generate the certificates.
-	var args []string
-	if p.certFile != "" {
-		args = append(args, "-cert-file", p.certFile)
+	if p.TempDir {
+		dir, err := makeTempDir()
+		if err != nil {
+			return Cert{}, fmt.Errorf("mkcert: %w", err)
+		}
+		p.Directory = dir
 	}
-	if p.keyFile != "" {
-		args = append(args, "-key-file", p.keyFile)
+
+	// Concurrent calls for the same certificate share a single mkcert run.
+	cert, err := flights.do(p.key(), func() (Cert, error) { return execute(p) })
+	cert.Domains = append([]string(nil), cert.Domains...)
+	cert.chain = new(chainCache)
+	if p.TempDir {
+		cert.tempDir = p.Directory
+		if cert.File == "" {
+			// mkcert failed, so there's nothing for the caller to clean up.
+			cert.Cleanup()
+		}
 	}
-	cmd := exec.Command("mkcert", append(args, p.domains...)...)
-	cmd.Dir = p.dir
-	out, err := cmd.CombinedOutput()
+	return cert, err
+}
 
-	if err != nil {
-		if perr, ok := err.(*exec.ExitError); ok {
-			perr.Stderr = out
+func execute(p params) (Cert, error) {
+	if p.Reuse {
+		if cert, ok := reuse(p); ok {
+			return cert, checkTrusted(cert, p)
 		}
-		return Cert{}, fmt.Errorf("mkcert: %w", err)
 	}
 
-	certFile, keyFile := parseFiles(out)
+	if !p.Overwrite {
+		if err := checkOverwrite(p); err != nil {
+			return Cert{}, err
+		}
+	}
+
+	// Ask mkcert to generate the certificates.
+	out, err := run(p, p.args()...)
+	if err != nil {
+		return Cert{}, err
+	}
+
+	rep, err := mkcertout.Parse(out)
+	if err != nil {
+		return Cert{}, err
+	}
 	cert := Cert{
-		CARoot:  parseCA(out),
-		Trusted: parseTrusted(out),
-		Domains: p.domains,
-		File:    certFile,
-		KeyFile: keyFile,
+		CARoot:    rep.CARoot,
+		Trusted:   rep.Trusted,
+		Untrusted: rep.Untrusted,
+		Domains:   p.Domains,
+		File:      rep.CertFile,
+		KeyFile:   rep.KeyFile,
+	}
+	if p.CacheTrust != nil && cert.CARoot != "" {
+		p.CacheTrust.put(p, Trust{CARoot: cert.CARoot, Trusted: cert.Trusted, Untrusted: cert.Untrusted, JavaHome: javaHome(p)})
 	}
-	if cmd.Dir != "" {
+	if p.Directory != "" {
 		if !filepath.IsAbs(cert.File) {
-			cert.File = filepath.Join(cmd.Dir, cert.File)
+			cert.File = filepath.Join(p.Directory, cert.File)
 		}
 		if !filepath.IsAbs(cert.KeyFile) {
-			cert.KeyFile = filepath.Join(cmd.Dir, cert.KeyFile)
+			cert.KeyFile = filepath.Join(p.Directory, cert.KeyFile)
 		}
 	}
-	if !cert.Trusted && p.requireTrust {
-		err = fmt.Errorf("mkcert: CA at %s not trusted, run mkcert -install", cert.CARoot)
+	if err := checkPair(cert.File, cert.KeyFile); err != nil {
+		return Cert{}, err
 	}
-	return cert, err
+	leaf, err := readCert(cert.File)
+	if err == nil {
+		cert.Usages = certUsages(leaf)
+	}
+	if p.Audit {
+		if err != nil {
+			return Cert{}, fmt.Errorf("mkcert: audit log: %w", err)
+		}
+		if err := audit(p, cert, leaf); err != nil {
+			return Cert{}, err
+		}
+	}
+	if p.Reuse && p.Directory != "" {
+		if err := stampCache(p.Directory); err != nil {
+			return Cert{}, fmt.Errorf("mkcert: %w", err)
+		}
+	}
+	return cert, checkTrusted(cert, p)
+}
+
+// args returns the arguments to mkcert for the certificate requested by p.
+func (p params) args() []string {
+	var args []string
+	if p.CertFile != "" {
+		args = append(args, "-cert-file", p.CertFile)
+	}
+	if p.KeyFile != "" {
+		args = append(args, "-key-file", p.KeyFile)
+	}
+	if p.ClientAuth {
+		args = append(args, "-client")
+	}
+	if p.ECDSA {
+		args = append(args, "-ecdsa")
+	}
+	return append(args, p.Domains...)
+}
+
+// command returns the mkcert command to run with args, and the variables it
+// sets on top of the inherited environment.
+func command(p params, args ...string) (*exec.Cmd, []string, error) {
+	bin, err := binary(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cmd *exec.Cmd
+	if p.ctx != nil {
+		cmd = exec.CommandContext(p.ctx, bin, args...)
+	} else {
+		cmd = exec.Command(bin, args...)
+	}
+	if p.Binary == "" {
+		// Keep the command line as it'd be typed.
+		cmd.Args[0] = "mkcert"
+	}
+	cmd.Dir = p.Directory
+	env := append(p.env(), javaEnv(p, args)...)
+	if env != nil || p.SecureEnv {
+		cmd.Env = append(p.environ(), env...)
+	}
+	return cmd, env, nil
+}
+
+// run invokes mkcert with args, returning its combined output.
+func run(p params, args ...string) ([]byte, error) {
+	cmd, env, err := command(p, args...)
+	if err != nil {
+		return nil, err
+	}
+	var out lockedBuffer
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&stdout, &out)
+	cmd.Stderr = io.MultiWriter(&stderr, &out)
+	unlock := lockCA(p)
+	start := time.Now()
+	err = cmd.Run()
+	unlock()
+
+	if p.result != nil {
+		p.result.Runs = append(p.result.Runs, Invocation{
+			Args:     cmd.Args,
+			Env:      env,
+			Dir:      cmd.Dir,
+			Duration: time.Since(start),
+			Stdout:   stdout.Bytes(),
+			Stderr:   stderr.Bytes(),
+			Err:      err,
+		})
+	}
+	if err != nil {
+		if perr, ok := err.(*exec.ExitError); ok {
+			perr.Stderr = out.buf.Bytes()
+		}
+		return nil, fmt.Errorf("mkcert: %w", err)
+	}
+	return out.buf.Bytes(), nil
 }
 
+// checkTrusted returns an error if trust is required of the CA but missing.
+func checkTrusted(cert Cert, p params) error {
+	nssRequired := p.RequireTrusted
+	for _, store := range p.RequireTrustedStores {
+		nssRequired = nssRequired || store == StoreNSS
+	}
+	if nssRequired && !hasCertutil() {
+		for _, u := range cert.Untrusted {
+			if u == StoreNSS {
+				return &CertutilError{CARoot: cert.CARoot, Install: certutilInstall()}
+			}
+		}
+	}
+	if !cert.Trusted && p.RequireTrusted {
+		return fmt.Errorf("mkcert: CA at %s not trusted, run mkcert -install", cert.CARoot)
+	}
+	for _, store := range p.RequireTrustedStores {
+		install := "mkcert -install"
+		if store == StoreJava {
+			home := javaHome(p)
+			if home == "" {
+				return fmt.Errorf("mkcert: no JDK found to check the java store for the CA at %s, set JAVA_HOME", cert.CARoot)
+			}
+			if p.getenv("JAVA_HOME") == "" {
+				install = "JAVA_HOME=" + home + " " + install
+			}
+		}
+		for _, u := range cert.Untrusted {
+			if u == store {
+				return fmt.Errorf("mkcert: CA at %s not trusted by the %s store, run %s", cert.CARoot, store, install)
+			}
+		}
+	}
+	return nil
+}
+
+// params are the options given, which set the fields of the Request, and
+// the state which isn't part of one.
 type params struct {
-	dir          string
-	certFile     string
-	keyFile      string
-	domains      []string
-	requireTrust bool
+	Request
+
+	result      *ExecResult
+	trustStores string
+
+	// ctx, when set, kills mkcert if it's done first. It's set by functions
+	// taking a context rather than by an option.
+	ctx context.Context
+}
+
+// key identifies the certificate requested by p.
+func (p params) key() string {
+	return fmt.Sprintf("%#v %p %q", p.Request, p.result, p.trustStores)
 }
 
 type Opt func(*params)
 
-// Domains is the list of domains to generate the certificate for.
+// Domains is the list of domains to generate the certificate for. Host names
+// are lowercased and stripped of trailing dots, and duplicates are dropped.
+// The first domain is the certificate's primary name, and the others are
+// sorted.
 func Domains(domains ...string) Opt {
-	return func(p *params) { p.domains = domains }
+	return func(p *params) { p.Domains = domains }
 }
 
 // RequireTrusted indicates whether Exec errors if the CA is not trusted.
 func RequireTrusted(req bool) Opt {
-	return func(p *params) { p.requireTrust = req }
+	return func(p *params) { p.RequireTrusted = req }
+}
+
+// RequireTrustedStores has Exec return an error if the CA is missing from any
+// of the named trust stores, such as StoreNSS for Firefox users on Linux,
+// where Firefox ignores the system store. Unlike RequireTrusted, other
+// stores may be missing the CA. Stores mkcert doesn't check, as listed in
+// Trust.Untrusted, aren't required.
+func RequireTrustedStores(stores ...string) Opt {
+	return func(p *params) { p.RequireTrustedStores = stores }
 }
 
 // Directory specifies the working directory of mkcert, and is the path relative
 // to which CertFile and KeyFile are relative to, if specified. When blank,
 // defaults to the current directory.
 func Directory(path string) Opt {
-	return func(p *params) { p.dir = path }
+	return func(p *params) { p.Directory = path }
+}
+
+// Binary is the mkcert program to run, found in PATH if it's only a name.
+// Defaults to "mkcert".
+func Binary(path string) Opt {
+	return func(p *params) { p.Binary = path }
 }
 
 // CertFile overrides the location of the generated certificate.
 func CertFile(path string) Opt {
-	return func(p *params) { p.certFile = path }
+	return func(p *params) { p.CertFile = path }
 }
 
 // KeyFile overrides the location of the generated private key.
 func KeyFile(path string) Opt {
-	return func(p *params) { p.keyFile = path }
+	return func(p *params) { p.KeyFile = path }
 }
-
-func parseCA(out []byte) string {
-	match := caRe.FindSubmatch(out)
-	if len(match) < 2 {
-		return ""
-	}
-	return string(match[1])
-}
-
-var caRe = regexp.MustCompile(`local CA at "(.+?)" [💥✨]\n`)
-
-func parseTrusted(out []byte) bool {
-	return !bytes.Contains(out, []byte("not installed"))
-}
-
-func parseFiles(out []byte) (cert, key string) {
-	match := fileRe.FindSubmatch(out)
-	if len(match) < 3 {
-		return "", ""
-	}
-	return string(match[1]), string(match[2])
-}
-
-var fileRe = regexp.MustCompile(`(?m)The certificate is at "(.+?)" and the key at "(.+?)"`)
@@ -0,0 +1,107 @@
+// Package mkcertout parses the output of the mkcert command, for programs
+// which run mkcert themselves. The parsing follows the messages printed by
+// mkcert 1.4, which are written for people rather than programs, so fields
+// are left blank when a message isn't recognised.
+package mkcertout
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+)
+
+// Names of the trust stores, as used by the TRUST_STORES envvar.
+const (
+	StoreSystem = "system"
+	StoreNSS    = "nss"
+	StoreJava   = "java"
+)
+
+// Report is what mkcert said it did.
+type Report struct {
+	// CARoot is the directory containing the root CA, if mkcert mentioned
+	// it. Newer releases of mkcert don't.
+	CARoot string
+	// CACreated indicates that mkcert created a new root CA.
+	CACreated bool
+	// Trusted indicates that mkcert didn't find any trust stores missing the
+	// root CA.
+	Trusted bool
+	// Untrusted lists the stores mkcert found to be missing the root CA, such
+	// as StoreNSS.
+	Untrusted []string
+	// Names are those mkcert said the new certificate is valid for.
+	Names []string
+	// CertFile and KeyFile are where a new certificate and its key were
+	// written, which are the same file when mkcert was asked to combine them.
+	CertFile, KeyFile string
+	// PKCS12File is where a new PKCS#12 bundle was written.
+	PKCS12File string
+	// Warnings are the warnings mkcert printed, such as that certutil isn't
+	// available to install the CA in the NSS store.
+	Warnings []string
+	// CertutilInstall is the command mkcert suggested to install certutil,
+	// if it needed certutil to install the CA but couldn't find it.
+	CertutilInstall string
+}
+
+// Parse reads the combined stdout and stderr of mkcert. It returns an error
+// if mkcert reported one, along with whatever could be parsed.
+func Parse(out []byte) (Report, error) {
+	rep := Report{
+		Trusted:   !bytes.Contains(out, []byte("not installed")),
+		CACreated: bytes.Contains(out, []byte("Created a new local CA")),
+	}
+	if m := caRe.FindSubmatch(out); m != nil {
+		rep.CARoot = string(m[1])
+	}
+	for _, m := range untrustedRe.FindAllSubmatch(out, -1) {
+		switch string(m[1]) {
+		case "system":
+			rep.Untrusted = append(rep.Untrusted, StoreSystem)
+		case "Java":
+			rep.Untrusted = append(rep.Untrusted, StoreJava)
+		default:
+			// mkcert names the browsers using NSS, which vary by platform.
+			rep.Untrusted = append(rep.Untrusted, StoreNSS)
+		}
+	}
+	if m := namesRe.FindSubmatch(out); m != nil {
+		for _, n := range nameRe.FindAllSubmatch(m[1], -1) {
+			rep.Names = append(rep.Names, string(n[1]))
+		}
+	}
+	if m := filesRe.FindSubmatch(out); m != nil {
+		rep.CertFile, rep.KeyFile = string(m[1]), string(m[2])
+	} else if m := combinedRe.FindSubmatch(out); m != nil {
+		rep.CertFile, rep.KeyFile = string(m[1]), string(m[1])
+	}
+	if m := pkcs12Re.FindSubmatch(out); m != nil {
+		rep.PKCS12File = string(m[1])
+	}
+	for _, m := range warningRe.FindAllSubmatch(out, -1) {
+		rep.Warnings = append(rep.Warnings, string(m[1]))
+	}
+
+	if m := certutilRe.FindSubmatch(out); m != nil {
+		rep.CertutilInstall = string(m[1])
+	}
+
+	if m := errorRe.FindSubmatch(out); m != nil {
+		return rep, errors.New("mkcert: " + string(m[1]))
+	}
+	return rep, nil
+}
+
+var (
+	caRe        = regexp.MustCompile(`local CA at "(.+?)" [💥✨]\n`)
+	untrustedRe = regexp.MustCompile(`(?m)^Note: the local CA is not installed in the (.+?) trust store`)
+	namesRe     = regexp.MustCompile(`(?m)^Created a new certificate valid for the following names.*\n((?: - ".*"\n)+)`)
+	nameRe      = regexp.MustCompile(`(?m)^ - "(.*)"$`)
+	filesRe     = regexp.MustCompile(`(?m)The certificate is at "(.+?)" and the key at "(.+?)"`)
+	combinedRe  = regexp.MustCompile(`(?m)The certificate and key are at "(.+?)"`)
+	pkcs12Re    = regexp.MustCompile(`(?m)The PKCS#12 bundle is at "(.+?)"`)
+	warningRe   = regexp.MustCompile(`(?m)^Warning: (.+?)\s*(?:⚠️)?\s*$`)
+	certutilRe  = regexp.MustCompile(`(?m)^Install "certutil" with "(.+?)"`)
+	errorRe     = regexp.MustCompile(`(?m)^ERROR: (.+)$`)
+)
@@ -0,0 +1,97 @@
+package mkcerttest
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/icio/mkcert"
+)
+
+// AssertCovers reports an error unless cert is valid for each of hosts.
+func AssertCovers(t testing.TB, cert mkcert.Cert, hosts ...string) {
+	t.Helper()
+	leaf, ok := readLeaf(t, cert.File)
+	if !ok {
+		return
+	}
+	var missing []string
+	for _, h := range hosts {
+		if leaf.VerifyHostname(h) != nil {
+			missing = append(missing, h)
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("mkcerttest: %s doesn't cover %s\n\tcovers: %s", cert.File, strings.Join(missing, ", "), strings.Join(sans(leaf), ", "))
+	}
+}
+
+// AssertTrusted reports an error unless mkcert found the CA of cert
+// installed in all of the trust stores.
+func AssertTrusted(t testing.TB, cert mkcert.Cert) {
+	t.Helper()
+	if !cert.Trusted {
+		t.Errorf("mkcerttest: CA at %s isn't trusted\n\tmissing from: %s", cert.CARoot, strings.Join(cert.Untrusted, ", "))
+	}
+}
+
+// AssertChainsTo reports an error unless cert verifies against the root CA
+// in caroot.
+func AssertChainsTo(t testing.TB, cert mkcert.Cert, caroot string) {
+	t.Helper()
+	cert.CARoot = caroot
+	err := cert.Verify("")
+	if err == nil {
+		return
+	}
+	leaf, ok := readLeaf(t, cert.File)
+	if !ok {
+		return
+	}
+	root, ok := readLeaf(t, filepath.Join(caroot, "rootCA.pem"))
+	if !ok {
+		return
+	}
+	t.Errorf("%v\n\tissuer: %s (key %x)\n\troot:   %s (key %x)\n\tvalid:  %s to %s",
+		err, leaf.Issuer, leaf.AuthorityKeyId, root.Subject, root.SubjectKeyId, leaf.NotBefore, leaf.NotAfter)
+}
+
+// readLeaf parses the first certificate in the PEM file at path, reporting
+// an error if it can't.
+func readLeaf(t testing.TB, path string) (*x509.Certificate, bool) {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Error(err)
+		return nil, false
+	}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			t.Errorf("mkcerttest: no certificate in %s", path)
+			return nil, false
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Errorf("mkcerttest: %s: %v", path, err)
+			return nil, false
+		}
+		return cert, true
+	}
+}
+
+// sans lists the names and addresses cert is valid for.
+func sans(cert *x509.Certificate) []string {
+	names := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
@@ -0,0 +1,325 @@
+// Command fakemkcert imitates the mkcert command line and output closely
+// enough for the mkcert package, so that it can be tested without mkcert
+// installed. It's built by mkcerttest.FakeBinary.
+//
+// Certificates are issued from a CA in $CAROOT as mkcert does, but -install
+// and -uninstall only record whether the CA is trusted in a file alongside
+// it, without touching any real trust store.
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// installedFile records the stores the CA has been "installed" in.
+const installedFile = ".fakemkcert-installed"
+
+// stores are the trust stores imitated, with the names mkcert reports them
+// by.
+var stores = []struct{ name, desc string }{
+	{"system", "system"},
+	{"nss", "Firefox and/or Chrome/Chromium"},
+	{"java", "Java"},
+}
+
+func main() {
+	log.SetFlags(0)
+
+	install := flag.Bool("install", false, "")
+	uninstall := flag.Bool("uninstall", false, "")
+	certFile := flag.String("cert-file", "", "")
+	keyFile := flag.String("key-file", "", "")
+	client := flag.Bool("client", false, "")
+	useECDSA := flag.Bool("ecdsa", false, "")
+	caroot := flag.Bool("CAROOT", false, "")
+	version := flag.Bool("version", false, "")
+	flag.Parse()
+
+	if *version {
+		fmt.Println("v1.4.1")
+		return
+	}
+	root := os.Getenv("CAROOT")
+	if root == "" {
+		root = filepath.Join(os.Getenv("HOME"), ".local", "share", "mkcert")
+	}
+	if *caroot {
+		fmt.Println(root)
+		return
+	}
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		log.Fatalf("ERROR: failed to create the CAROOT: %s", err)
+	}
+	caCert, caKey, err := loadCA(root)
+	if err != nil {
+		log.Fatalf("ERROR: failed to load the CA: %s", err)
+	}
+
+	marker := filepath.Join(root, installedFile)
+	b, _ := ioutil.ReadFile(marker)
+	installed := map[string]bool{}
+	for _, s := range strings.Fields(string(b)) {
+		installed[s] = true
+	}
+	switch {
+	case *install:
+		var record []string
+		for _, s := range stores {
+			if enabled(s.name) {
+				if installed[s.name] {
+					log.Printf("The local CA is already installed in the %s trust store! 👍", s.desc)
+				} else {
+					log.Printf("The local CA is now installed in the %s trust store! ⚡️", s.desc)
+				}
+				installed[s.name] = true
+			}
+			if installed[s.name] {
+				record = append(record, s.name)
+			}
+		}
+		if err := ioutil.WriteFile(marker, []byte(strings.Join(record, "\n")+"\n"), 0644); err != nil {
+			log.Fatalf("ERROR: failed to install the CA: %s", err)
+		}
+		log.Print("")
+	case *uninstall:
+		os.Remove(marker)
+		log.Print("The local CA is now uninstalled from the system trust store(s)! 👋")
+		log.Print("")
+		return
+	default:
+		warn := false
+		for _, s := range stores {
+			if enabled(s.name) && !installed[s.name] {
+				log.Printf("Note: the local CA is not installed in the %s trust store.", s.desc)
+				warn = true
+			}
+		}
+		if warn {
+			log.Print("Run \"mkcert -install\" for certificates to be trusted automatically ⚠️")
+		}
+	}
+
+	hosts := flag.Args()
+	if len(hosts) == 0 {
+		if !*install {
+			fmt.Fprintln(os.Stderr, "Usage of mkcert:\n\n\t$ mkcert -install\n\t$ mkcert example.org")
+		}
+		return
+	}
+	if err := issue(caCert, caKey, hosts, *certFile, *keyFile, *client, *useECDSA); err != nil {
+		log.Fatalf("ERROR: %s", err)
+	}
+}
+
+// enabled reports whether TRUST_STORES includes the store. As with mkcert,
+// the java store is only considered when JAVA_HOME is set.
+func enabled(store string) bool {
+	if store == "java" && os.Getenv("JAVA_HOME") == "" {
+		return false
+	}
+	env := os.Getenv("TRUST_STORES")
+	if env == "" {
+		return true
+	}
+	for _, s := range strings.Split(env, ",") {
+		if s == store {
+			return true
+		}
+	}
+	return false
+}
+
+// issue writes a certificate for hosts, reporting it as mkcert does.
+func issue(caCert *x509.Certificate, caKey crypto.Signer, hosts []string, certFile, keyFile string, client, useECDSA bool) error {
+	tmpl := &x509.Certificate{
+		SerialNumber: serial(),
+		Subject:      pkix.Name{Organization: []string{"mkcert development certificate"}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().AddDate(2, 3, 0),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if strings.Contains(h, "@") {
+			tmpl.EmailAddresses = append(tmpl.EmailAddresses, h)
+		} else if u, err := url.Parse(h); err == nil && u.Scheme != "" && u.Host != "" {
+			tmpl.URIs = append(tmpl.URIs, u)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	if client {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
+	}
+	if len(tmpl.IPAddresses) > 0 || len(tmpl.DNSNames) > 0 || len(tmpl.URIs) > 0 {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+	}
+	if len(tmpl.EmailAddresses) > 0 {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageEmailProtection)
+	}
+
+	var key crypto.Signer
+	var err error
+	if useECDSA {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate key: %s", err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, key.Public(), caKey)
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode certificate key: %s", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	name := strings.Replace(hosts[0], ":", "_", -1)
+	name = strings.Replace(name, "*", "_wildcard", -1)
+	if len(hosts) > 1 {
+		name += fmt.Sprintf("+%d", len(hosts)-1)
+	}
+	if client {
+		name += "-client"
+	}
+	if certFile == "" {
+		certFile = "./" + name + ".pem"
+	}
+	if keyFile == "" {
+		keyFile = "./" + name + "-key.pem"
+	}
+	if certFile == keyFile {
+		if err := ioutil.WriteFile(keyFile, append(certPEM, keyPEM...), 0600); err != nil {
+			return fmt.Errorf("failed to save certificate and key: %s", err)
+		}
+	} else {
+		if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+			return fmt.Errorf("failed to save certificate: %s", err)
+		}
+		if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return fmt.Errorf("failed to save certificate key: %s", err)
+		}
+	}
+
+	log.Printf("\nCreated a new certificate valid for the following names 📜")
+	for _, h := range hosts {
+		log.Printf(" - %q", h)
+	}
+	if certFile == keyFile {
+		log.Printf("\nThe certificate and key are at \"%s\" ✅\n\n", certFile)
+	} else {
+		log.Printf("\nThe certificate is at \"%s\" and the key at \"%s\" ✅\n\n", certFile, keyFile)
+	}
+	return nil
+}
+
+// loadCA loads the CA from root, creating it if it doesn't exist.
+func loadCA(root string) (*x509.Certificate, crypto.Signer, error) {
+	certPath := filepath.Join(root, "rootCA.pem")
+	keyPath := filepath.Join(root, "rootCA-key.pem")
+	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		if err := newCA(certPath, keyPath); err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Created a new local CA at \"%s\" 💥\n", root)
+	} else {
+		log.Printf("Using the local CA at \"%s\" ✨\n", root)
+	}
+
+	cert, err := readPEM(certPath, "CERTIFICATE")
+	if err != nil {
+		return nil, nil, err
+	}
+	caCert, err := x509.ParseCertificate(cert)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := readPEM(keyPath, "PRIVATE KEY")
+	if err != nil {
+		return nil, nil, err
+	}
+	caKey, err := x509.ParsePKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := caKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("unsupported CA key")
+	}
+	return caCert, signer, nil
+}
+
+func newCA(certPath, keyPath string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial(),
+		Subject:               pkix.Name{Organization: []string{"mkcert development CA"}, CommonName: "fakemkcert"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0400); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+func readPEM(path, typ string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != typ {
+		return nil, fmt.Errorf("no %s in %s", typ, path)
+	}
+	return block.Bytes, nil
+}
+
+func serial() *big.Int {
+	s, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		log.Fatalf("ERROR: failed to generate serial number: %s", err)
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+package mkcerttest
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/icio/mkcert"
+)
+
+// MITMProxy is an HTTP proxy which intercepts the TLS connections clients
+// tunnel through it with CONNECT, as TLS-terminating corporate proxies do. It
+// presents certificates issued on the fly by its Manager, and makes its own
+// requests to the origin servers.
+type MITMProxy struct {
+	// URL is the proxy's address, for http.ProxyURL.
+	URL *url.URL
+	// Manager issues the certificates presented to clients. Its HostPolicy
+	// may be set to refuse hosts.
+	Manager *mkcert.Manager
+	// Transport makes the requests to the origin servers. It trusts the
+	// system roots and the mkcert root CA.
+	Transport *http.Transport
+
+	srv   *httptest.Server
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+}
+
+// NewMITMProxy starts a MITMProxy, whose Manager issues certificates with
+// opts, which is closed when the test ends.
+func NewMITMProxy(t testing.TB, opts ...mkcert.Opt) *MITMProxy {
+	t.Helper()
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	trust, err := mkcert.TrustStatus(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pem, err := ioutil.ReadFile(filepath.Join(trust.CARoot, "rootCA.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots.AppendCertsFromPEM(pem)
+
+	p := &MITMProxy{
+		Manager:   &mkcert.Manager{Opts: opts},
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+		conns:     make(map[net.Conn]bool),
+	}
+	p.srv = httptest.NewServer(p)
+	p.URL, _ = url.Parse(p.srv.URL)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// Client returns an http.Client trusting the mkcert root CA which sends its
+// requests through p.
+func (p *MITMProxy) Client(t testing.TB) *http.Client {
+	t.Helper()
+	c := NewTLSClient(t, nil, p.Manager.Opts...)
+	c.Transport.(*http.Transport).Proxy = http.ProxyURL(p.URL)
+	return c
+}
+
+// Close stops the proxy and closes the intercepted connections.
+func (p *MITMProxy) Close() {
+	p.srv.Close()
+	p.mu.Lock()
+	for c := range p.conns {
+		c.Close()
+	}
+	p.mu.Unlock()
+	p.Transport.CloseIdleConnections()
+}
+
+func (p *MITMProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		// Plain HTTP is proxied as it is.
+		rp := &httputil.ReverseProxy{
+			Rewrite:   func(r *httputil.ProxyRequest) { r.Out.Host = "" },
+			Transport: p.Transport,
+		}
+		rp.ServeHTTP(w, r)
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		conn.Close()
+		return
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName == "" {
+				// Clients connecting by IP address don't send one.
+				hello.ServerName = host
+			}
+			return p.Manager.GetCertificate(hello)
+		},
+	})
+	p.intercept(tlsConn, r.Host)
+}
+
+// intercept relays the requests made over conn to the origin at addr.
+func (p *MITMProxy) intercept(conn *tls.Conn, addr string) {
+	p.mu.Lock()
+	p.conns[conn] = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.conns, conn)
+		p.mu.Unlock()
+		conn.Close()
+	}()
+
+	br := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		req.URL.Scheme, req.URL.Host = "https", addr
+		req.RequestURI = ""
+		resp, err := p.Transport.RoundTrip(req)
+		if err != nil {
+			resp = &http.Response{
+				StatusCode: http.StatusBadGateway,
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+				Body:       http.NoBody,
+				Close:      true,
+			}
+		}
+		err = resp.Write(conn)
+		resp.Body.Close()
+		if err != nil || resp.Close || req.Close {
+			return
+		}
+	}
+}
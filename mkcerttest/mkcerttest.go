@@ -0,0 +1,151 @@
+// Package mkcerttest provides helpers for testing TLS clients and servers
+// with certificates from mkcert. A test needing both ends can use:
+//
+//	srv := mkcerttest.NewServer(t, handler)
+//	resp, err := mkcerttest.NewTLSClient(t, nil).Get(srv.URL)
+//
+// Tests can have a CA of their own, and run in parallel, by passing the
+// option returned by TempCA to each helper:
+//
+//	ca := mkcerttest.TempCA(t)
+//	srv := mkcerttest.NewServer(t, handler, ca)
+//	resp, err := mkcerttest.NewTLSClient(t, nil, ca).Get(srv.URL)
+package mkcerttest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/icio/mkcert"
+)
+
+// NewServer starts an httptest.Server serving h over TLS with an mkcert
+// certificate for localhost, 127.0.0.1 and ::1, issued with opts, with
+// HTTP/2 enabled. It's closed when the test ends.
+func NewServer(t testing.TB, h http.Handler, opts ...mkcert.Opt) *httptest.Server {
+	t.Helper()
+	cert := Certificate(t, []string{"localhost", "127.0.0.1", "::1"}, opts...)
+
+	srv := httptest.NewUnstartedServer(h)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// NewTLSClient returns an http.Client trusting the mkcert root CA used for
+// opts, which presents clientCerts when servers ask for one. Its Transport is
+// an *http.Transport, whose idle connections are closed when the test ends.
+func NewTLSClient(t testing.TB, clientCerts []tls.Certificate, opts ...mkcert.Opt) *http.Client {
+	t.Helper()
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs:      RootCAs(t, opts...),
+			Certificates: clientCerts,
+		},
+		ForceAttemptHTTP2: true,
+	}
+	t.Cleanup(tr.CloseIdleConnections)
+	return &http.Client{Transport: tr}
+}
+
+// Certificate issues a certificate for domains with mkcert, using opts. Its
+// files are removed once loaded.
+func Certificate(t testing.TB, domains []string, opts ...mkcert.Opt) tls.Certificate {
+	t.Helper()
+	cert, err := mkcert.Exec(append(opts, mkcert.Domains(domains...), mkcert.TempDir())...)
+	defer cert.Cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(cert.File, cert.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+// RootCAs returns a pool containing only the mkcert root CA used for opts.
+func RootCAs(t testing.TB, opts ...mkcert.Opt) *x509.CertPool {
+	t.Helper()
+	trust, err := mkcert.TrustStatus(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pem, err := ioutil.ReadFile(filepath.Join(trust.CARoot, "rootCA.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		t.Fatalf("mkcerttest: no certificates in %s", filepath.Join(trust.CARoot, "rootCA.pem"))
+	}
+	return pool
+}
+
+// TempCA creates a new CA in a temporary CAROOT, running mkcert with opts,
+// and returns the mkcert.CARoot option using it, for passing to the other
+// helpers here and to mkcert. The CA is removed when the test ends and isn't
+// installed in any trust store, so it can't be used with RequireTrusted.
+// Each test can have CAs of its own, including tests run in parallel.
+func TempCA(t testing.TB, opts ...mkcert.Opt) mkcert.Opt {
+	t.Helper()
+	ca := mkcert.CARoot(t.TempDir())
+
+	// mkcert creates the CA the first time it's run.
+	if _, err := mkcert.TrustStatus(append(opts, ca)...); err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+// FakeBinary builds fakemkcert, a stand-in for mkcert which only pretends to
+// install its CA, for tests to run where mkcert isn't available. It returns
+// the path of the binary, which is removed when the test ends, for use with
+// mkcert.Binary. The go command must be in PATH.
+func FakeBinary(t testing.TB) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "mkcert")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	cmd := exec.Command("go", "build", "-o", bin, "github.com/icio/mkcert/mkcerttest/fakemkcert")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("mkcerttest: building fakemkcert: %v\n%s", err, out)
+	}
+	return bin
+}
+
+// UseFake returns the mkcert.Binary option running FakeBinary in place of
+// mkcert, for passing to the other helpers here and to mkcert. Unlike
+// changing PATH, it can be used in parallel tests.
+func UseFake(t testing.TB) mkcert.Opt {
+	t.Helper()
+	return mkcert.Binary(FakeBinary(t))
+}
+
+// SkipIfUnavailable skips the test if mkcert isn't installed or usable.
+func SkipIfUnavailable(t testing.TB) {
+	t.Helper()
+	if _, err := mkcert.Available(); err != nil {
+		t.Skip(err)
+	}
+}
+
+// Manager returns a mkcert.Manager using opts which is closed when the test
+// ends. Unless opts include a Directory, certificates are kept in a temporary
+// directory removed at the same time.
+func Manager(t testing.TB, opts ...mkcert.Opt) *mkcert.Manager {
+	t.Helper()
+	m := &mkcert.Manager{Opts: append([]mkcert.Opt{mkcert.Directory(t.TempDir())}, opts...)}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
@@ -0,0 +1,58 @@
+package mkcerttest_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/icio/mkcert"
+	"github.com/icio/mkcert/mkcerttest"
+)
+
+// TestParallelCAs checks that parallel tests can use CAs of their own, two at
+// once, with clients trusting only theirs.
+func TestParallelCAs(t *testing.T) {
+	fake := mkcerttest.UseFake(t)
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			a := []mkcert.Opt{fake, mkcerttest.TempCA(t, fake)}
+			b := []mkcert.Opt{fake, mkcerttest.TempCA(t, fake)}
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})
+			srvA := mkcerttest.NewServer(t, handler, a...)
+			srvB := mkcerttest.NewServer(t, handler, b...)
+
+			for _, c := range []struct {
+				name    string
+				client  *http.Client
+				url     string
+				trusted bool
+			}{
+				{"a to a", mkcerttest.NewTLSClient(t, nil, a...), srvA.URL, true},
+				{"b to b", mkcerttest.NewTLSClient(t, nil, b...), srvB.URL, true},
+				{"a to b", mkcerttest.NewTLSClient(t, nil, a...), srvB.URL, false},
+				{"b to a", mkcerttest.NewTLSClient(t, nil, b...), srvA.URL, false},
+			} {
+				resp, err := c.client.Get(c.url)
+				if !c.trusted {
+					if err == nil {
+						resp.Body.Close()
+						t.Errorf("%s: other CA trusted", c.name)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("%s: %v", c.name, err)
+					continue
+				}
+				body, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil || string(body) != "ok" {
+					t.Errorf("%s: got %q, %v; want ok", c.name, body, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package mkcert
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSP answers the DER-encoded OCSP request with a response, in DER, signed
+// by the CA used for opts and valid for CRLValidity. Certificates revoked
+// with Revoke are reported revoked, and others in the audit log read by List
+// for opts are reported good. Any other serial, or a request for another CA,
+// gets the unknown status, or an unauthorized response, as the CA can't vouch
+// for certificates it has no record of. As with CRL, mkcert doesn't put an
+// OCSP server in the certificates it issues, so clients have to be pointed
+// at OCSPHandler themselves.
+func OCSP(request []byte, opts ...Opt) ([]byte, error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	req, err := ocsp.ParseRequest(request)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse, nil
+	}
+	caroot, root, key, err := loadCA(p)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := issuedBy(req, root.RawSubject, root.RawSubjectPublicKeyInfo); err != nil {
+		return nil, fmt.Errorf("mkcert: ocsp: %w", err)
+	} else if !ok {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+
+	serial := fmt.Sprintf("%x", req.SerialNumber)
+	now := time.Now().UTC()
+	tmpl := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(CRLValidity),
+	}
+	issued, err := List(opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range issued {
+		if i.CARoot != caroot || i.Serial != serial {
+			continue
+		}
+		tmpl.Status = ocsp.Good
+		if i.Revoked != nil {
+			tmpl.Status, tmpl.RevokedAt = ocsp.Revoked, *i.Revoked
+		}
+	}
+	resp, err := ocsp.CreateResponse(root, root, tmpl, key)
+	if err != nil {
+		return nil, fmt.Errorf("mkcert: ocsp: %w", err)
+	}
+	return resp, nil
+}
+
+// issuedBy reports whether req asks about a certificate issued by the CA with
+// the subject and public key.
+func issuedBy(req *ocsp.Request, subject, spki []byte) (bool, error) {
+	var info struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(spki, &info); err != nil {
+		return false, err
+	}
+	if !req.HashAlgorithm.Available() {
+		return false, nil
+	}
+	hash := func(b []byte) []byte {
+		h := req.HashAlgorithm.New()
+		h.Write(b)
+		return h.Sum(nil)
+	}
+	return bytes.Equal(req.IssuerNameHash, hash(subject)) &&
+		bytes.Equal(req.IssuerKeyHash, hash(info.PublicKey.RightAlign())), nil
+}
+
+// OCSPHandler is an OCSP responder, answering requests with OCSP for opts,
+// whether POSTed or base64-encoded in the path of a GET, as in RFC 6960
+// appendix A. Mounted beneath a prefix, it should be given the rest of the
+// path, such as by http.StripPrefix.
+func OCSPHandler(opts ...Opt) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req []byte
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			req, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 10000))
+		case http.MethodGet:
+			var enc string
+			if enc, err = url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/")); err == nil {
+				req, err = base64.StdEncoding.DecodeString(enc)
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			http.Error(w, "bad OCSP request", http.StatusBadRequest)
+			return
+		}
+		resp, err := OCSP(req, opts...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	})
+}
@@ -0,0 +1,56 @@
+package mkcert
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Overwrite allows Exec to have mkcert replace existing files at CertFile and
+// KeyFile which aren't a certificate for the requested Domains. Without it,
+// Exec returns an *OverwriteError rather than run mkcert, so that a
+// misdirected call doesn't clobber certificates maintained by hand. Files
+// holding a certificate for exactly the requested Domains are replaced
+// either way, as when renewing it.
+func Overwrite(overwrite bool) Opt {
+	return func(p *params) { p.Overwrite = overwrite }
+}
+
+// OverwriteError is returned by Exec when the certificate or key it would
+// write already exists and isn't for the requested domains, unless
+// Overwrite(true) is given.
+type OverwriteError struct {
+	// File is the existing file, the certificate or the key.
+	File string
+	// Domains are those of the certificate in File, or nil if it isn't a
+	// certificate or is the key of a certificate that doesn't exist.
+	Domains []string
+}
+
+func (e *OverwriteError) Error() string {
+	if e.Domains == nil {
+		return fmt.Sprintf("mkcert: %s already exists and isn't for the requested domains; use Overwrite(true) to replace it", e.File)
+	}
+	return fmt.Sprintf("mkcert: %s already exists for %s; use Overwrite(true) to replace it", e.File, strings.Join(e.Domains, ", "))
+}
+
+// checkOverwrite returns an *OverwriteError if mkcert would replace a
+// certificate or key which isn't for the Domains of p.
+func checkOverwrite(p params) error {
+	certFile, keyFile := p.files()
+	if _, err := os.Stat(certFile); err == nil {
+		leaf, err := readCert(certFile)
+		if err != nil {
+			return &OverwriteError{File: certFile}
+		}
+		if !coversExactly(leaf, p.Domains) {
+			return &OverwriteError{File: certFile, Domains: certNames(leaf)}
+		}
+		// The key belongs to the certificate being replaced.
+		return nil
+	}
+	if _, err := os.Stat(keyFile); err == nil {
+		return &OverwriteError{File: keyFile}
+	}
+	return nil
+}
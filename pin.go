@@ -0,0 +1,44 @@
+package mkcert
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+)
+
+// Pin is the base64 SHA-256 digest of a certificate's SubjectPublicKeyInfo,
+// by which clients can pin a public key rather than a certificate, and so
+// keep trusting it when the certificate's reissued with the same key.
+type Pin string
+
+// SPKIPin returns the Pin of cert's public key.
+func SPKIPin(cert *x509.Certificate) Pin {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return Pin(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// Pins returns the Pins of the certificate in File and of the root CA in
+// CARoot.
+func (c Cert) Pins() (leaf, root Pin, err error) {
+	chain, err := c.Chain()
+	if err != nil {
+		return "", "", err
+	}
+	return SPKIPin(chain[0]), SPKIPin(chain[len(chain)-1]), nil
+}
+
+// HPKP formats p as a pin-sha256 directive, as in Public-Key-Pins headers and
+// the configs modelled on them.
+func (p Pin) HPKP() string {
+	return `pin-sha256="` + string(p) + `"`
+}
+
+// Android formats p as a pin element of an Android network security config.
+func (p Pin) Android() string {
+	return `<pin digest="SHA-256">` + string(p) + `</pin>`
+}
+
+// Curl formats p for curl's --pinnedpubkey.
+func (p Pin) Curl() string {
+	return "sha256//" + string(p)
+}
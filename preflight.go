@@ -0,0 +1,207 @@
+package mkcert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Report is the readiness of mkcert, as found by Preflight, for tools to
+// show as a checklist before they run it.
+type Report struct {
+	// Binary is the path of the mkcert program, or blank if it wasn't
+	// found, with BinaryErr saying why.
+	Binary    string
+	BinaryErr error
+	// Version is the version mkcert reports, such as "v1.4.4".
+	Version string
+
+	// CARoot is the directory of the CA mkcert will use, which CARootExists
+	// reports exists. CARootWritable reports whether mkcert can write to it,
+	// or create it, as it must to create the CA.
+	CARoot         string
+	CARootExists   bool
+	CARootWritable bool
+	// CAPresent reports whether the CA's certificate and key are in the
+	// CARoot. Without them, mkcert creates a new CA when it's next run.
+	CAPresent bool
+
+	// Stores are the trust stores mkcert knows, in the order StoreSystem,
+	// StoreNSS and StoreJava.
+	Stores []StoreReport
+	// Certutil reports whether certutil, which mkcert needs for the nss
+	// store, was found. If not, CertutilInstall is the command installing
+	// it on this machine, or blank if there's no known package.
+	Certutil        bool
+	CertutilInstall string
+	// JavaHome is the JDK mkcert checks for the java store, or blank if
+	// there's none.
+	JavaHome string
+
+	// Actions are what's needed before mkcert will issue trusted
+	// certificates, such as "mkcert -install", in the order to do them.
+	// There are none when everything's ready.
+	Actions []string
+
+	// nssUntrusted is set if mkcert reported the nss store untrusted.
+	nssUntrusted bool
+}
+
+// StoreReport is the state of a trust store in a Report.
+type StoreReport struct {
+	// Name is StoreSystem, StoreNSS or StoreJava.
+	Name string
+	// Enabled reports whether mkcert uses the store, according to
+	// TRUST_STORES.
+	Enabled bool
+	// Checked reports whether mkcert could check the store, which it can't
+	// for the nss store without certutil, or the java store without a JDK,
+	// or any store without a CA.
+	Checked bool
+	// Trusted reports whether the CA is in the store.
+	Trusted bool
+}
+
+// Ready reports whether there are no actions needed.
+func (r *Report) Ready() bool {
+	return len(r.Actions) == 0
+}
+
+// Preflight checks what's needed for mkcert to issue trusted certificates
+// with opts, without changing anything: unlike TrustStatus, it doesn't have
+// mkcert create a missing CA. Problems found are given in the Report rather
+// than as errors; the error is only that of ctx, if it's done first, in which
+// case mkcert is killed.
+func Preflight(ctx context.Context, opts ...Opt) (*Report, error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	p.ctx = ctx
+	r := &Report{Certutil: hasCertutil(), JavaHome: javaHome(p)}
+	if !r.Certutil {
+		r.CertutilInstall = certutilInstall()
+	}
+	for _, name := range []string{StoreSystem, StoreNSS, StoreJava} {
+		r.Stores = append(r.Stores, StoreReport{Name: name, Enabled: storeEnabled(p, name)})
+	}
+	err := r.check(p)
+	if ctx.Err() != nil {
+		return r, ctx.Err()
+	}
+	r.plan(err == nil)
+	return r, nil
+}
+
+// check fills in r, returning an error if the stores couldn't be checked.
+func (r *Report) check(p params) error {
+	if r.Binary, r.BinaryErr = binary(p); r.BinaryErr != nil {
+		return r.BinaryErr
+	}
+	if out, err := run(p, "-version"); err == nil {
+		r.Version = string(bytes.TrimSpace(out))
+	}
+
+	caroot, err := findCARoot(p)
+	if err != nil {
+		return err
+	}
+	if caroot == "" {
+		return errors.New("mkcert: no CAROOT")
+	}
+	r.CARoot = caroot
+	if fi, err := os.Stat(caroot); err == nil && fi.IsDir() {
+		r.CARootExists = true
+	}
+	r.CARootWritable = writable(caroot)
+	_, certErr := os.Stat(filepath.Join(caroot, "rootCA.pem"))
+	_, keyErr := os.Stat(filepath.Join(caroot, "rootCA-key.pem"))
+	r.CAPresent = certErr == nil && keyErr == nil
+	if !r.CAPresent {
+		return errors.New("mkcert: no CA")
+	}
+
+	t, err := trustStatus(p)
+	if err != nil {
+		return err
+	}
+	for i := range r.Stores {
+		s := &r.Stores[i]
+		switch s.Name {
+		case StoreNSS:
+			s.Checked = s.Enabled && r.Certutil
+		case StoreJava:
+			s.Checked = s.Enabled && r.JavaHome != ""
+		default:
+			s.Checked = s.Enabled
+		}
+		s.Trusted = s.Checked
+		for _, u := range t.Untrusted {
+			if u == s.Name {
+				s.Trusted = false
+				r.nssUntrusted = r.nssUntrusted || u == StoreNSS
+			}
+		}
+	}
+	return nil
+}
+
+// plan fills in the Actions of r, given whether its stores were checked.
+func (r *Report) plan(checked bool) {
+	if r.Binary == "" {
+		r.Actions = append(r.Actions, installCommand())
+		return
+	}
+	if r.CARoot == "" {
+		return
+	}
+	if !r.CAPresent {
+		if !r.CARootWritable {
+			r.Actions = append(r.Actions, "make "+r.CARoot+" writable")
+		}
+		r.Actions = append(r.Actions, "create the CA")
+	}
+
+	install := !checked
+	for _, s := range r.Stores {
+		if !s.Enabled {
+			continue
+		}
+		// Without certutil, mkcert only reports the nss store untrusted if
+		// there are browser profiles needing it.
+		if s.Name == StoreNSS && !r.Certutil && (!checked || r.nssUntrusted) {
+			if r.CertutilInstall != "" {
+				r.Actions = append(r.Actions, r.CertutilInstall)
+			} else {
+				r.Actions = append(r.Actions, "install certutil from the NSS tools")
+			}
+			install = true
+		}
+		if s.Checked && !s.Trusted {
+			install = true
+		}
+	}
+	if install {
+		r.Actions = append(r.Actions, "mkcert -install")
+	}
+}
+
+// writable reports whether a file can be created in dir, or dir created in
+// the nearest directory which exists.
+func writable(dir string) bool {
+	for {
+		if fi, err := os.Stat(dir); err == nil {
+			if !fi.IsDir() {
+				return false
+			}
+			return canWrite(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
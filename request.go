@@ -0,0 +1,120 @@
+package mkcert
+
+import (
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// Request describes a certificate for Do, as a struct in place of Exec's
+// options, for building requests field by field, serializing them, or
+// generating the code making them. Each field corresponds to the option of
+// the same name, which is left unset when the field is the zero value: the
+// options set the fields of a Request, from which Exec works. Fields are
+// added to Request along with options, so that requests written with field
+// names keep compiling.
+type Request struct {
+	Domains              []string      `json:"domains"`
+	Directory            string        `json:"directory,omitempty"`
+	CertFile             string        `json:"cert_file,omitempty"`
+	KeyFile              string        `json:"key_file,omitempty"`
+	TempDir              bool          `json:"temp_dir,omitempty"`
+	Binary               string        `json:"binary,omitempty"`
+	BinaryNames          []string      `json:"binary_names,omitempty"`
+	CARoot               string        `json:"caroot,omitempty"`
+	RequireTrusted       bool          `json:"require_trusted,omitempty"`
+	RequireTrustedStores []string      `json:"require_trusted_stores,omitempty"`
+	Reuse                bool          `json:"reuse,omitempty"`
+	RenewBefore          time.Duration `json:"renew_before,omitempty"`
+	ECDSA                bool          `json:"ecdsa,omitempty"`
+	ClientAuth           bool          `json:"client_auth,omitempty"`
+	Wildcards            bool          `json:"wildcards,omitempty"`
+	LocalhostAliases     bool          `json:"localhost_aliases,omitempty"`
+	Overwrite            bool          `json:"overwrite,omitempty"`
+	Keychain             string        `json:"keychain,omitempty"`
+	// SecureEnv and KeepEnv are the SecureEnv option and its arguments.
+	SecureEnv bool     `json:"secure_env,omitempty"`
+	KeepEnv   []string `json:"keep_env,omitempty"`
+	// Audit is set for the Audit option, with AuditLabel its label.
+	Audit      bool   `json:"audit,omitempty"`
+	AuditLabel string `json:"audit_label,omitempty"`
+	AuditFile  string `json:"audit_file,omitempty"`
+	// CacheTrust is the cache given with the CacheTrust option. It isn't
+	// serialized.
+	CacheTrust *TrustCache `json:"-"`
+
+	// Trace records the runs of mkcert in Response.Runs, as with the Result
+	// option. Traced requests aren't coalesced with others.
+	Trace bool `json:"trace,omitempty"`
+}
+
+// Response is the outcome of a Request.
+type Response struct {
+	// Cert is the certificate issued, or reused.
+	Cert Cert
+	// Duration is how long Do took.
+	Duration time.Duration
+	// Runs are the invocations of mkcert, in order, when Request.Trace is
+	// set.
+	Runs []Invocation
+}
+
+// Do issues the certificate described by req, as Exec does for the
+// corresponding options. The Response is returned even if Do fails, for its
+// Runs. Exec remains for code using options.
+func Do(req Request) (*Response, error) {
+	p := req.params()
+	var res ExecResult
+	if req.Trace {
+		p.result = &res
+	}
+	start := time.Now()
+	cert, err := execCert(p)
+	return &Response{Cert: cert, Duration: time.Since(start), Runs: res.Runs}, err
+}
+
+// Apply sets the fields of r given by opts, so that options can be used to
+// build a Request. The Result option has no field, so is ignored.
+func (r *Request) Apply(opts ...Opt) {
+	p := r.params()
+	for _, o := range opts {
+		o(&p)
+	}
+	*r = p.Request
+}
+
+// Opts returns the options setting the fields of r which aren't the zero
+// value, such as for Manager.Opts.
+func (r Request) Opts() []Opt {
+	r = r.normalize()
+	return []Opt{func(p *params) {
+		src, dst := reflect.ValueOf(r), reflect.ValueOf(&p.Request).Elem()
+		for i := 0; i < src.NumField(); i++ {
+			if f := src.Field(i); !f.IsZero() {
+				dst.Field(i).Set(f)
+			}
+		}
+	}}
+}
+
+// params returns the params set by the options corresponding to r.
+func (r Request) params() params {
+	return params{Request: r.normalize()}
+}
+
+// normalize returns r as its options would set it: with CARoot and
+// AuditFile absolute, and Audit set by AuditFile.
+func (r Request) normalize() Request {
+	if r.CARoot != "" {
+		if abs, err := filepath.Abs(r.CARoot); err == nil {
+			r.CARoot = abs
+		}
+	}
+	if r.AuditFile != "" {
+		if abs, err := filepath.Abs(r.AuditFile); err == nil {
+			r.AuditFile = abs
+		}
+		r.Audit = true
+	}
+	return r
+}
@@ -0,0 +1,56 @@
+package mkcert
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// ExecResult records what happened during a call to Exec, for diagnosing
+// problems with mkcert. It's populated by passing the Result option.
+type ExecResult struct {
+	// Cert and Err are what Exec returned.
+	Cert Cert
+	Err  error
+	// Duration is how long Exec took.
+	Duration time.Duration
+	// Runs are the invocations of mkcert, in order. There may be none when
+	// Reuse finds an existing certificate and its trust status is cached.
+	Runs []Invocation
+}
+
+// Invocation describes a run of mkcert.
+type Invocation struct {
+	// Args is the command line, starting with the program name.
+	Args []string
+	// Env are the variables set on top of the inherited environment.
+	Env []string
+	// Dir is the working directory, or blank for the current directory.
+	Dir string
+	// Duration is how long mkcert ran for.
+	Duration time.Duration
+	// Stdout and Stderr are mkcert's output.
+	Stdout, Stderr []byte
+	// Err is the error mkcert failed with, if any.
+	Err error
+}
+
+// Result populates r with the details of the call to Exec. TrustStatus and
+// Install only append to r.Runs. Calls with a Result aren't coalesced with
+// other calls, so that r describes the mkcert runs they made.
+func Result(r *ExecResult) Opt {
+	return func(p *params) { p.result = r }
+}
+
+// lockedBuffer is a bytes.Buffer safe for writing concurrently, for
+// collecting stdout and stderr together.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
@@ -0,0 +1,168 @@
+package mkcert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// reuseMargin is how long before expiry a certificate is no longer reused,
+// unless overridden by RenewBefore.
+const reuseMargin = 7 * 24 * time.Hour
+
+// Reuse indicates whether Exec may return a previously generated certificate
+// rather than invoking mkcert to create a new one. A certificate is reused if
+// it exists at the requested path with a matching key, covers exactly the
+// requested domains, was issued by the current CA, and isn't close to expiry.
+// The layout of the Directory is versioned, so certificates written by other
+// versions of this package are migrated, or else regenerated.
+func Reuse(reuse bool) Opt {
+	return func(p *params) { p.Reuse = reuse }
+}
+
+// RenewBefore sets how long before expiry Reuse stops returning an existing
+// certificate, so that it's replaced. Defaults to a week.
+func RenewBefore(d time.Duration) Opt {
+	return func(p *params) { p.RenewBefore = d }
+}
+
+// reuse returns the existing certificate for p, if any still fits the bill.
+func reuse(p params) (Cert, bool) {
+	if p.Directory != "" && !cacheUsable(p.Directory) {
+		return Cert{}, false
+	}
+	certFile, keyFile := p.files()
+
+	margin := p.RenewBefore
+	if margin == 0 {
+		margin = reuseMargin
+	}
+	leaf, err := readCert(certFile)
+	if err != nil || !coversExactly(leaf, p.Domains) || time.Until(leaf.NotAfter) < margin {
+		return Cert{}, false
+	}
+	usages := certUsages(leaf)
+	if (p.ClientAuth && !hasUsage(usages, UsageClientAuth)) || isECDSA(leaf) != p.ECDSA {
+		return Cert{}, false
+	}
+	if checkPair(certFile, keyFile) != nil {
+		return Cert{}, false
+	}
+
+	trust, err := trustStatus(p)
+	if err != nil {
+		return Cert{}, false
+	}
+	root, err := readCert(filepath.Join(trust.CARoot, "rootCA.pem"))
+	if err != nil || leaf.CheckSignatureFrom(root) != nil {
+		return Cert{}, false
+	}
+
+	return Cert{
+		CARoot:    trust.CARoot,
+		Trusted:   trust.Trusted,
+		Untrusted: trust.Untrusted,
+		Domains:   p.Domains,
+		File:      certFile,
+		KeyFile:   keyFile,
+		Usages:    usages,
+	}, true
+}
+
+// files returns the paths mkcert writes the certificate and key requested by
+// p to.
+func (p params) files() (certFile, keyFile string) {
+	certFile, keyFile = p.CertFile, p.KeyFile
+	if certFile == "" || keyFile == "" {
+		defCert, defKey := DefaultFiles(p.Domains...)
+		if p.ClientAuth {
+			defCert, defKey = clientFiles(defCert, defKey)
+		}
+		if certFile == "" {
+			certFile = defCert
+		}
+		if keyFile == "" {
+			keyFile = defKey
+		}
+	}
+	if p.Directory != "" {
+		if !filepath.IsAbs(certFile) {
+			certFile = filepath.Join(p.Directory, certFile)
+		}
+		if !filepath.IsAbs(keyFile) {
+			keyFile = filepath.Join(p.Directory, keyFile)
+		}
+	}
+	return certFile, keyFile
+}
+
+// findCARoot asks mkcert where its CA lives.
+func findCARoot(p params) (string, error) {
+	out, err := run(p, "-CAROOT")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// DefaultFiles returns the names mkcert gives the certificate and key for
+// domains when CertFile and KeyFile aren't specified. They are relative to
+// the Directory mkcert is run in. The domains are normalized as by Exec.
+func DefaultFiles(domains ...string) (cert, key string) {
+	domains = normalizeDomains(domains)
+	if len(domains) == 0 {
+		return "", ""
+	}
+	name := strings.Replace(domains[0], ":", "_", -1)
+	name = strings.Replace(name, "*", "_wildcard", -1)
+	if len(domains) > 1 {
+		name += fmt.Sprintf("+%d", len(domains)-1)
+	}
+	return "./" + name + ".pem", "./" + name + "-key.pem"
+}
+
+// coversExactly reports whether the subject alternative names of cert are the
+// same set as domains.
+func coversExactly(cert *x509.Certificate, domains []string) bool {
+	sans := certNames(cert)
+	want := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		if ip := net.ParseIP(d); ip != nil {
+			d = ip.String()
+		}
+		want[strings.ToLower(d)] = true
+	}
+	have := make(map[string]bool, len(sans))
+	for _, s := range sans {
+		if !want[strings.ToLower(s)] {
+			return false
+		}
+		have[strings.ToLower(s)] = true
+	}
+	return len(have) == len(want)
+}
+
+// readCert parses the first certificate in the PEM file at path.
+func readCert(path string) (*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("mkcert: no certificate in " + path)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package mkcert
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RevokedFileName is the name of the file in the CAROOT recording the
+// certificates revoked with Revoke.
+const RevokedFileName = "revoked.jsonl"
+
+// CRLValidity is how long the CRLs returned by CRL are valid for.
+const CRLValidity = 24 * time.Hour
+
+// revocation is a line of the RevokedFileName file.
+type revocation struct {
+	Serial string    `json:"serial"`
+	Time   time.Time `json:"time"`
+}
+
+// Revoke marks the certificate with the serial number, in hex as in
+// AuditRecord.Serial or as printed by openssl, revoked by its CA, so that
+// it's listed in the CRLs returned by CRL. The certificate must be in the
+// audit log read by List for opts, as that's where its CA is found. Revoking
+// a certificate twice has no further effect.
+func Revoke(serial string, opts ...Opt) error {
+	n, ok := new(big.Int).SetString(strings.ReplaceAll(serial, ":", ""), 16)
+	if !ok {
+		return fmt.Errorf("mkcert: revoke: bad serial %q, expected hex", serial)
+	}
+	serial = fmt.Sprintf("%x", n)
+	issued, err := List(opts...)
+	if err != nil {
+		return err
+	}
+	for _, i := range issued {
+		if i.Serial == serial {
+			return revoke(i.CARoot, serial)
+		}
+	}
+	return fmt.Errorf("mkcert: revoke: no certificate with serial %s in the audit log", serial)
+}
+
+// revoke records serial revoked in caroot, holding the CA's lock so that
+// runs in other processes don't revoke it too.
+func revoke(caroot, serial string) error {
+	unlock := lockDir(caroot)
+	defer unlock()
+	revoked, err := readRevoked(caroot)
+	if err != nil {
+		return err
+	}
+	if _, ok := revoked[serial]; ok {
+		return nil
+	}
+	line, err := json.Marshal(revocation{Serial: serial, Time: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(caroot, RevokedFileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("mkcert: revoke: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("mkcert: revoke: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("mkcert: revoke: %w", err)
+	}
+	return nil
+}
+
+// CRL returns a certificate revocation list, in DER, of the certificates
+// revoked with Revoke, signed by the CA used for opts, valid for
+// CRLValidity. mkcert doesn't put a CRL distribution point in the
+// certificates it issues, so clients checking revocation have to be given
+// the CRL, such as with x509.ParseRevocationList. As mkcert's roots don't
+// have the cRLSign key usage, RevocationList.CheckSignatureFrom rejects the
+// CRL, and its signature should be checked with the root's CheckSignature.
+// Revocations are also given in OCSP responses, by OCSP.
+func CRL(opts ...Opt) ([]byte, error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	caroot, root, key, err := loadCA(p)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := readRevoked(caroot)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(now.UnixNano()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(CRLValidity),
+	}
+	for serial, t := range revoked {
+		n, ok := new(big.Int).SetString(serial, 16)
+		if !ok {
+			return nil, fmt.Errorf("mkcert: crl: bad serial %q in %s", serial, RevokedFileName)
+		}
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   n,
+			RevocationTime: t,
+		})
+	}
+	// mkcert's roots only have the certSign key usage, which
+	// CreateRevocationList refuses to sign CRLs for.
+	issuer := *root
+	issuer.KeyUsage |= x509.KeyUsageCRLSign
+	crl, err := x509.CreateRevocationList(rand.Reader, tmpl, &issuer, key)
+	if err != nil {
+		return nil, fmt.Errorf("mkcert: crl: %w", err)
+	}
+	return crl, nil
+}
+
+// loadCA reads the root certificate and key of the CA used for p.
+func loadCA(p params) (caroot string, root *x509.Certificate, key crypto.Signer, err error) {
+	caroot = p.CARoot
+	if caroot == "" {
+		if caroot, err = findCARoot(p); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	if root, err = readCert(filepath.Join(caroot, "rootCA.pem")); err != nil {
+		return "", nil, nil, fmt.Errorf("mkcert: %w", err)
+	}
+	if key, err = readCAKey(filepath.Join(caroot, "rootCA-key.pem")); err != nil {
+		return "", nil, nil, err
+	}
+	return caroot, root, key, nil
+}
+
+// readRevoked returns when each certificate revoked by the CA in caroot was
+// revoked, by serial number.
+func readRevoked(caroot string) (map[string]time.Time, error) {
+	revoked := make(map[string]time.Time)
+	f, err := os.Open(filepath.Join(caroot, RevokedFileName))
+	if os.IsNotExist(err) {
+		return revoked, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("mkcert: revoked: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var r revocation
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return nil, fmt.Errorf("mkcert: revoked: %w", err)
+		}
+		if _, ok := revoked[r.Serial]; !ok {
+			revoked[r.Serial] = r.Time
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("mkcert: revoked: %w", err)
+	}
+	return revoked, nil
+}
+
+// readCAKey parses the private key mkcert keeps alongside its root.
+func readCAKey(path string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("mkcert: %w", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("mkcert: no key in " + path)
+	}
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mkcert: %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("mkcert: unsupported key in " + path)
+	}
+	return signer, nil
+}
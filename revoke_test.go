@@ -0,0 +1,70 @@
+package mkcert_test
+
+import (
+	"testing"
+
+	"github.com/icio/mkcert"
+	"github.com/icio/mkcert/mkcerttest"
+	"golang.org/x/crypto/ocsp"
+)
+
+// TestOCSP checks that certificates in the audit log are reported good by
+// OCSP until they're revoked, and that others are unknown.
+func TestOCSP(t *testing.T) {
+	opts := []mkcert.Opt{mkcerttest.UseFake(t)}
+	opts = append(opts, mkcerttest.TempCA(t, opts...))
+
+	status := func(cert mkcert.Cert) int {
+		t.Helper()
+		chain, err := cert.Chain()
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := ocsp.CreateRequest(chain[0], chain[1], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := mkcert.OCSP(req, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := ocsp.ParseResponseForCert(der, chain[0], chain[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Status
+	}
+
+	audited, err := mkcert.Exec(append(opts, mkcert.Domains("audited.test"), mkcert.TempDir(), mkcert.Audit(""))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audited.Cleanup()
+	unaudited, err := mkcert.Exec(append(opts, mkcert.Domains("unaudited.test"), mkcert.TempDir())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unaudited.Cleanup()
+
+	if got := status(audited); got != ocsp.Good {
+		t.Errorf("audited certificate has status %d, want good", got)
+	}
+	if got := status(unaudited); got != ocsp.Unknown {
+		t.Errorf("unaudited certificate has status %d, want unknown", got)
+	}
+
+	issued, err := mkcert.List(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issued) != 1 || issued[0].CARoot != audited.CARoot {
+		t.Fatalf("audit log lists %+v, want the one certificate", issued)
+	}
+	// Serials may be given with the leading zero openssl prints.
+	if err := mkcert.Revoke("00"+issued[0].Serial, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(audited); got != ocsp.Revoked {
+		t.Errorf("revoked certificate has status %d, want revoked", got)
+	}
+}
@@ -0,0 +1,125 @@
+package mkcert
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Rotation describes the replacement of a root CA by CA.Rotate.
+type Rotation struct {
+	// Retired is the directory the old root CA was moved to. It's still
+	// installed in any trust stores it was before, and can be removed from
+	// them with CAROOT=<Retired> mkcert -uninstall.
+	Retired string
+	// Reissued are the certificates issued again from the new root CA.
+	Reissued []Cert
+	// Trust is the trust status of the new root CA. It needs installing with
+	// Install, or mkcert -install, into the stores in Trust.Untrusted.
+	Trust Trust
+}
+
+// Rotate retires the root CA of ca, moving it to a timestamped directory
+// under retired in ca.Dir, and has mkcert create a new one. The certs, such
+// as those found by CachedCerts, are then issued again from the new root to
+// the same files, keeping their type of key, and client auth if they had it.
+// The certificates which couldn't be issued again are reported in the error,
+// with the others in the Rotation.
+//
+// Rotate shouldn't be called while certificates are being issued from ca.
+// Managers using ca should be reloaded afterwards, with Manager.Reload.
+func (ca *CA) Rotate(certs []Cert, opts ...Opt) (*Rotation, error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	retired := filepath.Join(ca.Dir, "retired", time.Now().UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(filepath.Dir(retired), 0700); err != nil {
+		return nil, fmt.Errorf("mkcert: %w", err)
+	}
+	// Never overwrite an earlier retired CA.
+	if err := os.Mkdir(retired, 0700); err != nil {
+		return nil, fmt.Errorf("mkcert: %w", err)
+	}
+	for _, name := range []string{"rootCA.pem", "rootCA-key.pem"} {
+		if err := os.Rename(filepath.Join(ca.Dir, name), filepath.Join(retired, name)); err != nil {
+			return nil, fmt.Errorf("mkcert: retiring the CA: %w", err)
+		}
+	}
+	rot := &Rotation{Retired: retired}
+
+	// Checking the trust status has mkcert create the new CA.
+	var err error
+	if p.CacheTrust != nil {
+		p.CacheTrust.Invalidate()
+	}
+	rot.Trust, err = ca.TrustStatus(opts...)
+	if err != nil {
+		return rot, err
+	}
+	var errs []error
+	for _, c := range certs {
+		leaf, err := readCert(c.File)
+		ecdsa := err == nil && isECDSA(leaf)
+		cert, err := ca.Exec(append(opts, Domains(c.Domains...), CertFile(c.File), KeyFile(c.KeyFile), ClientAuth(c.HasUsage(UsageClientAuth)), ECDSA(ecdsa), Reuse(false))...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.File, err))
+			continue
+		}
+		rot.Reissued = append(rot.Reissued, cert)
+	}
+	return rot, errors.Join(errs...)
+}
+
+// CachedCerts returns the certificates in dir, such as a Directory used with
+// Reuse: each file holding a certificate, with its key alongside in the
+// -key.pem file mkcert names for it. Their Domains are the names the
+// certificates cover, and CARoot is left blank.
+func CachedCerts(dir string) ([]Cert, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var certs []Cert
+	for _, fi := range files {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || !strings.HasSuffix(name, ".pem") || strings.HasSuffix(name, "-key.pem") {
+			continue
+		}
+		certFile := filepath.Join(dir, name)
+		keyFile := strings.TrimSuffix(certFile, ".pem") + "-key.pem"
+		if _, err := os.Stat(keyFile); err != nil {
+			continue
+		}
+		leaf, err := readCert(certFile)
+		if err != nil || leaf.IsCA {
+			continue
+		}
+		certs = append(certs, Cert{
+			Domains: normalizeDomains(certNames(leaf)),
+			File:    certFile,
+			KeyFile: keyFile,
+			Usages:  certUsages(leaf),
+		})
+	}
+	return certs, nil
+}
+
+// certNames returns the subject alternative names of cert.
+func certNames(cert *x509.Certificate) []string {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
@@ -0,0 +1,60 @@
+package mkcert
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Run runs the command argv, attached to this process's stdin, stdout and
+// stderr, with the environment set up for common tools to trust the mkcert
+// root CA as well as the system roots:
+//
+//   - SSL_CERT_FILE, for OpenSSL and Go programs;
+//   - NODE_EXTRA_CA_CERTS and DENO_CERT, for Node.js and Deno;
+//   - REQUESTS_CA_BUNDLE and PIP_CERT, for Python requests and pip;
+//   - CURL_CA_BUNDLE and GIT_SSL_CAINFO, for curl and git.
+//
+// Those replacing the system roots point at a bundle of them with the
+// mkcert root CA added, which is removed after the command exits. The
+// command is killed if ctx is done first.
+func Run(ctx context.Context, argv []string, opts ...Opt) error {
+	if len(argv) == 0 {
+		return errors.New("mkcert: no command to run")
+	}
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	trust, err := trustStatus(p)
+	if err != nil {
+		return err
+	}
+	root := filepath.Join(trust.CARoot, "rootCA.pem")
+
+	dir, err := ioutil.TempDir("", "mkcert")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	bundle := filepath.Join(dir, "ca-bundle.pem")
+	if _, err := WriteBundle(bundle, opts...); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(),
+		"SSL_CERT_FILE="+bundle,
+		"NODE_EXTRA_CA_CERTS="+root,
+		"DENO_CERT="+root,
+		"REQUESTS_CA_BUNDLE="+bundle,
+		"PIP_CERT="+bundle,
+		"CURL_CA_BUNDLE="+bundle,
+		"GIT_SSL_CAINFO="+bundle,
+	)
+	return cmd.Run()
+}
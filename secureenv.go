@@ -0,0 +1,59 @@
+package mkcert
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// SecureEnv runs mkcert with only the environment variables it and the trust
+// store tools it runs need, such as HOME, PATH, CAROOT, TRUST_STORES and
+// JAVA_HOME, rather than the whole environment of this process, so that
+// credentials in the environment aren't passed on. The variables named by
+// keep are passed on too.
+func SecureEnv(keep ...string) Opt {
+	return func(p *params) {
+		p.SecureEnv = true
+		p.KeepEnv = keep
+	}
+}
+
+// secureEnvVars are the variables SecureEnv passes on: those mkcert reads,
+// those its default CAROOT and the trust stores are found with, and those
+// sudo and certutil need to prompt the user.
+var secureEnvVars = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "TMPDIR", "LANG", "LC_ALL", "TERM",
+	"CAROOT", "TRUST_STORES", "JAVA_HOME", "XDG_DATA_HOME", "SUDO_ASKPASS",
+}
+
+// secureEnvVarsWindows are the variables SecureEnv passes on too on Windows,
+// where the system paths are also in the environment.
+var secureEnvVarsWindows = []string{
+	"USERPROFILE", "USERNAME", "HOMEDRIVE", "HOMEPATH", "LOCALAPPDATA", "APPDATA",
+	"ProgramData", "ProgramFiles", "SystemRoot", "SystemDrive", "windir",
+	"TEMP", "TMP", "PATHEXT", "ComSpec",
+}
+
+// environ returns the environment mkcert inherits, before the variables of
+// p.env are added.
+func (p params) environ() []string {
+	if !p.SecureEnv {
+		return os.Environ()
+	}
+	keep := append(append([]string(nil), secureEnvVars...), p.KeepEnv...)
+	if runtime.GOOS == "windows" {
+		keep = append(keep, secureEnvVarsWindows...)
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, k := range keep {
+			// Windows' names aren't case sensitive.
+			if name == k || (runtime.GOOS == "windows" && strings.EqualFold(name, k)) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
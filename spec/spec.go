@@ -0,0 +1,305 @@
+// Package spec issues the certificates listed in a spec file, for checking
+// the certificates a project needs into its repository in place of scattered
+// calls to mkcert.Exec. A spec is JSON, or YAML such as:
+//
+//	dir: certs
+//	certs:
+//	  web:
+//	    domains: [localhost, 127.0.0.1]
+//	  api:
+//	    domains: [api.localhost]
+//	    key_type: ecdsa
+//	    client: true
+//	    formats:
+//	      pkcs12: api.p12
+//	      k8s: api-secret.yaml
+//
+// Apply brings the files to the state the spec describes: each certificate
+// is issued to its cert and key files, by default name.pem and name-key.pem,
+// unless a valid one's already there, and converted to each of its formats,
+// as by package export.
+package spec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/icio/mkcert"
+	"github.com/icio/mkcert/export"
+	"go.yaml.in/yaml/v3"
+)
+
+// Spec lists the certificates to issue.
+type Spec struct {
+	// Dir is the directory the files are written to, relative to the spec
+	// file. Defaults to the spec's own directory.
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	// CARoot is the mkcert CA to issue from, relative to the spec file.
+	// Defaults to mkcert's own.
+	CARoot string `json:"caroot,omitempty" yaml:"caroot,omitempty"`
+	// Certs are the certificates, by name.
+	Certs map[string]Cert `json:"certs" yaml:"certs"`
+
+	// Opts are given to mkcert.Exec for every certificate, such as
+	// mkcert.Binary or mkcert.RequireTrusted.
+	Opts []mkcert.Opt `json:"-" yaml:"-"`
+
+	// base is the directory of the spec file.
+	base string
+}
+
+// Cert describes a certificate in a Spec.
+type Cert struct {
+	// Domains are those the certificate covers, as for mkcert.Domains.
+	Domains []string `json:"domains" yaml:"domains"`
+	// CertFile and KeyFile are where the certificate and key are written,
+	// relative to Dir. Default to the certificate's name with .pem and
+	// -key.pem.
+	CertFile string `json:"cert,omitempty" yaml:"cert,omitempty"`
+	KeyFile  string `json:"key,omitempty" yaml:"key,omitempty"`
+	// KeyType is "rsa", the default, or "ecdsa".
+	KeyType string `json:"key_type,omitempty" yaml:"key_type,omitempty"`
+	// Client has the certificate issued for client authentication too, as
+	// with mkcert.ClientAuth.
+	Client bool `json:"client,omitempty" yaml:"client,omitempty"`
+	// Formats are the files to convert the certificate into, by format, one
+	// of export.Formats, relative to Dir.
+	Formats map[string]string `json:"formats,omitempty" yaml:"formats,omitempty"`
+	// Password protects pkcs12 and jks keystores. Defaults to "changeit".
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// Alias names the key in jks keystores. Defaults to "mkcert".
+	Alias string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	// Namespace is that of the k8s Secret, which is named name-tls.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// TLSAUsage, TLSASelector and TLSAMatching are the fields of the tlsa
+	// record, as for export.Bundle.TLSA. Default to 3, 1 and 1, as with
+	// certexport: the SHA-256 of the certificate's public key.
+	TLSAUsage    *uint8 `json:"tlsa_usage,omitempty" yaml:"tlsa_usage,omitempty"`
+	TLSASelector *uint8 `json:"tlsa_selector,omitempty" yaml:"tlsa_selector,omitempty"`
+	TLSAMatching *uint8 `json:"tlsa_matching,omitempty" yaml:"tlsa_matching,omitempty"`
+}
+
+// LoadSpec reads the spec file at path, which is decoded as JSON if it ends
+// in .json, and as YAML otherwise. Relative paths in the spec are relative
+// to the file.
+func LoadSpec(path string) (*Spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := Parse(f, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("spec: %s: %w", path, errors.Unwrap(err))
+	}
+	s.base = filepath.Dir(path)
+	return s, nil
+}
+
+// Parse decodes a spec from r, as JSON if isJSON and as YAML otherwise.
+// Relative paths in the spec are relative to the current directory. Unknown
+// fields are an error.
+func Parse(r io.Reader, isJSON bool) (*Spec, error) {
+	var s Spec
+	var err error
+	if isJSON {
+		err = decodeJSON(r, &s)
+	} else {
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		err = dec.Decode(&s)
+	}
+	if err == nil {
+		err = s.validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("spec: %w", err)
+	}
+	return &s, nil
+}
+
+func decodeJSON(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func (s *Spec) validate() error {
+	if len(s.Certs) == 0 {
+		return errors.New("no certs")
+	}
+	for _, name := range s.names() {
+		c := s.Certs[name]
+		if len(c.Domains) == 0 {
+			return fmt.Errorf("%s: no domains", name)
+		}
+		switch c.KeyType {
+		case "", "rsa", "ecdsa":
+		default:
+			return fmt.Errorf("%s: unknown key_type %q, expected rsa or ecdsa", name, c.KeyType)
+		}
+		for format := range c.Formats {
+			if !known(format) {
+				return fmt.Errorf("%s: unknown format %q, expected one of: %s", name, format, strings.Join(export.Formats, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func known(format string) bool {
+	for _, f := range export.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply issues each of the certificates in s which is missing, no longer
+// matches the spec, or is close to expiry, as with mkcert.Reuse, and writes
+// its formats if the certificate changed or they're missing. It carries on
+// past certificates which fail, returning their errors together, and stops
+// between certificates once ctx is done.
+func (s *Spec) Apply(ctx context.Context) ([]mkcert.Cert, error) {
+	var certs []mkcert.Cert
+	var errs []error
+	for _, name := range s.names() {
+		if err := ctx.Err(); err != nil {
+			return certs, errors.Join(append(errs, err)...)
+		}
+		cert, err := s.apply(name, s.Certs[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("spec: %s: %w", name, err))
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	return certs, errors.Join(errs...)
+}
+
+func (s *Spec) apply(name string, c Cert) (mkcert.Cert, error) {
+	certFile, keyFile := c.CertFile, c.KeyFile
+	if certFile == "" {
+		certFile = name + ".pem"
+	}
+	if keyFile == "" {
+		keyFile = name + "-key.pem"
+	}
+	certFile, keyFile = s.path(certFile), s.path(keyFile)
+	if err := os.MkdirAll(filepath.Dir(certFile), 0755); err != nil {
+		return mkcert.Cert{}, err
+	}
+
+	opts := append([]mkcert.Opt(nil), s.Opts...)
+	if s.CARoot != "" {
+		opts = append(opts, mkcert.CARoot(s.rel(s.CARoot)))
+	}
+	before, _ := ioutil.ReadFile(certFile)
+	cert, err := mkcert.Exec(append(opts,
+		mkcert.Domains(c.Domains...),
+		mkcert.CertFile(certFile),
+		mkcert.KeyFile(keyFile),
+		mkcert.ECDSA(c.KeyType == "ecdsa"),
+		mkcert.ClientAuth(c.Client),
+		mkcert.Reuse(true),
+		// The files are the spec's, so are replaced when its domains change.
+		mkcert.Overwrite(true),
+	)...)
+	if err != nil {
+		return mkcert.Cert{}, err
+	}
+	if len(c.Formats) == 0 {
+		return cert, nil
+	}
+	after, err := ioutil.ReadFile(cert.File)
+	if err != nil {
+		return cert, err
+	}
+	changed := !bytes.Equal(before, after)
+
+	o := export.Options{Password: c.Password, Alias: c.Alias, Name: name + "-tls", Namespace: c.Namespace}
+	if o.Password == "" {
+		o.Password = "changeit"
+	}
+	if o.Alias == "" {
+		o.Alias = "mkcert"
+	}
+	o.TLSAUsage, o.TLSASelector, o.TLSAMatching = 3, 1, 1
+	if c.TLSAUsage != nil {
+		o.TLSAUsage = *c.TLSAUsage
+	}
+	if c.TLSASelector != nil {
+		o.TLSASelector = *c.TLSASelector
+	}
+	if c.TLSAMatching != nil {
+		o.TLSAMatching = *c.TLSAMatching
+	}
+	var b *export.Bundle
+	formats := make([]string, 0, len(c.Formats))
+	for format := range c.Formats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	for _, format := range formats {
+		path := s.path(c.Formats[format])
+		if _, err := os.Stat(path); err == nil && !changed {
+			continue
+		}
+		if b == nil {
+			if b, err = export.Load(cert); err != nil {
+				return cert, err
+			}
+		}
+		data, err := b.Encode(format, o)
+		if err != nil {
+			return cert, err
+		}
+		perm := os.FileMode(0644)
+		if export.Private(format) {
+			perm = 0600
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return cert, err
+		}
+		if err := mkcert.WriteFileAtomic(path, data, perm); err != nil {
+			return cert, err
+		}
+	}
+	return cert, nil
+}
+
+// names returns the names of the certificates, in order.
+func (s *Spec) names() []string {
+	names := make([]string, 0, len(s.Certs))
+	for name := range s.Certs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// path resolves the path of a file written by the spec.
+func (s *Spec) path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.rel(s.Dir), path)
+}
+
+// rel resolves path relative to the spec file.
+func (s *Spec) rel(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.base, path)
+}
@@ -0,0 +1,67 @@
+package mkcert
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// TempDir runs mkcert in a new temporary directory, which is removed by
+// Cert.Cleanup or CleanupTempDirs. It takes the place of Directory, so can't
+// be used with it, or with a CertFile or KeyFile outside the directory.
+func TempDir() Opt {
+	return func(p *params) { p.TempDir = true }
+}
+
+// Cleanup removes the temporary directory holding the certificate and key, if
+// they were generated using TempDir. The certificate files can't be used
+// afterwards.
+func (c Cert) Cleanup() error {
+	if c.tempDir == "" {
+		return nil
+	}
+	tempDirs.Lock()
+	delete(tempDirs.dirs, c.tempDir)
+	tempDirs.Unlock()
+	return os.RemoveAll(c.tempDir)
+}
+
+// CleanupTempDirs removes all temporary directories created by TempDir which
+// haven't been removed by Cert.Cleanup. Go has no hook to run when the
+// process exits, so programs should defer it in main:
+//
+//	defer mkcert.CleanupTempDirs()
+func CleanupTempDirs() error {
+	tempDirs.Lock()
+	dirs := tempDirs.dirs
+	tempDirs.dirs = nil
+	tempDirs.Unlock()
+
+	var err error
+	for dir := range dirs {
+		if rerr := os.RemoveAll(dir); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// tempDirs tracks the directories created by TempDir.
+var tempDirs struct {
+	sync.Mutex
+	dirs map[string]bool
+}
+
+func makeTempDir() (string, error) {
+	dir, err := ioutil.TempDir("", "mkcert")
+	if err != nil {
+		return "", err
+	}
+	tempDirs.Lock()
+	defer tempDirs.Unlock()
+	if tempDirs.dirs == nil {
+		tempDirs.dirs = make(map[string]bool)
+	}
+	tempDirs.dirs[dir] = true
+	return dir, nil
+}
@@ -0,0 +1,155 @@
+package mkcert
+
+import (
+	"sync"
+
+	"github.com/icio/mkcert/mkcertout"
+)
+
+// Names of the trust stores mkcert installs its CA into, as used by the
+// TRUST_STORES envvar.
+const (
+	StoreSystem = mkcertout.StoreSystem
+	StoreNSS    = mkcertout.StoreNSS
+	StoreJava   = mkcertout.StoreJava
+)
+
+// Trust describes the mkcert CA and whether it's trusted.
+type Trust struct {
+	// CARoot is the mkcert directory containing its root CA.
+	CARoot string
+	// Trusted indicates that the root CA is installed in all of the system
+	// trust stores.
+	Trusted bool
+	// Untrusted lists the stores mkcert found to be missing the root CA.
+	// Stores mkcert didn't check, such as NSS when there's no Firefox or
+	// Chrome profile, aren't included.
+	Untrusted []string
+	// JavaHome is the JDK whose cacerts was checked for the java store: the
+	// one in JAVA_HOME, or else the one providing the java command. It's
+	// blank if there's no JDK to check.
+	JavaHome string
+	// Keychain is the macOS keychain holding the root CA, KeychainSystem or
+	// KeychainLogin, or blank if it's in neither or this isn't macOS.
+	Keychain string
+}
+
+// TrustStatus invokes mkcert to locate its CA and check whether it's trusted,
+// without generating a certificate. mkcert will create the CA if it doesn't
+// exist yet.
+func TrustStatus(opts ...Opt) (Trust, error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	return trustStatus(p)
+}
+
+// Install invokes mkcert -install to add the CA to the trust stores, and
+// returns the resulting trust status. Any TrustCache given with CacheTrust is
+// invalidated. On macOS, the Keychain option chooses where the CA is
+// installed for the system store. If mkcert couldn't install the CA in the
+// nss store for lack of certutil, the trust status is returned with a
+// *CertutilError.
+func Install(opts ...Opt) (Trust, error) {
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	if err := p.validate(); err != nil {
+		return Trust{}, err
+	}
+	install, err := installKeychain(p)
+	if err != nil {
+		return Trust{}, err
+	}
+	out, err := run(install, "-install")
+	if p.CacheTrust != nil {
+		p.CacheTrust.Invalidate()
+	}
+	if err != nil {
+		return Trust{}, err
+	}
+	rep, _ := mkcertout.Parse(out)
+	t, err := trustStatus(p)
+	if err == nil && rep.CertutilInstall != "" {
+		err = &CertutilError{CARoot: t.CARoot, Install: rep.CertutilInstall}
+	}
+	return t, err
+}
+
+// TrustCache remembers the trust status reported by mkcert, so that calls
+// sharing the cache through CacheTrust needn't invoke mkcert to check it
+// again. Trust rarely changes while a process runs, but Invalidate should be
+// called if the CA is installed or uninstalled other than through Install.
+// The zero value is an empty cache ready to use.
+type TrustCache struct {
+	mu    sync.Mutex
+	trust map[string]Trust
+}
+
+// CacheTrust shares trust status between Exec and TrustStatus calls given
+// the same cache.
+func CacheTrust(c *TrustCache) Opt {
+	return func(p *params) { p.CacheTrust = c }
+}
+
+// Invalidate forgets all cached trust status.
+func (c *TrustCache) Invalidate() {
+	c.mu.Lock()
+	c.trust = nil
+	c.mu.Unlock()
+}
+
+func (c *TrustCache) get(p params) (Trust, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.trust[trustKey(p)]
+	return t, ok
+}
+
+func (c *TrustCache) put(p params, t Trust) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.trust == nil {
+		c.trust = make(map[string]Trust)
+	}
+	c.trust[trustKey(p)] = t
+}
+
+// trustKey identifies the CA and stores that mkcert will consider, which are
+// determined by its environment.
+func trustKey(p params) string {
+	return p.getenv("CAROOT") + "\x00" + p.getenv("TRUST_STORES") + "\x00" + p.getenv("JAVA_HOME")
+}
+
+func trustStatus(p params) (Trust, error) {
+	if p.CacheTrust != nil {
+		if t, ok := p.CacheTrust.get(p); ok {
+			return t, nil
+		}
+	}
+
+	// Running mkcert without any domains reports on the CA without
+	// generating anything.
+	out, err := run(p)
+	if err != nil {
+		return Trust{}, err
+	}
+	rep, err := mkcertout.Parse(out)
+	if err != nil {
+		return Trust{}, err
+	}
+	t := Trust{CARoot: rep.CARoot, Trusted: rep.Trusted, Untrusted: rep.Untrusted, JavaHome: javaHome(p)}
+	if t.CARoot == "" {
+		// Newer mkcert releases don't mention where the CA is.
+		if t.CARoot, err = findCARoot(p); err != nil {
+			return Trust{}, err
+		}
+	}
+	t.Keychain = findKeychain(t.CARoot)
+	if p.CacheTrust != nil {
+		p.CacheTrust.put(p, t)
+	}
+	return t, nil
+}
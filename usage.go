@@ -0,0 +1,65 @@
+package mkcert
+
+import (
+	"crypto/x509"
+	"strings"
+)
+
+// Extended key usages recorded in Cert.Usages.
+const (
+	// UsageServerAuth is for TLS servers, and is given to certificates for
+	// host names, IPs and URIs.
+	UsageServerAuth = "serverAuth"
+	// UsageClientAuth is for TLS clients, and is given with ClientAuth.
+	UsageClientAuth = "clientAuth"
+	// UsageEmailProtection is for S/MIME, and is given to certificates for
+	// email addresses.
+	UsageEmailProtection = "emailProtection"
+)
+
+// ClientAuth has mkcert issue the certificate for TLS client authentication
+// too, with mkcert -client, so that one certificate covers both ends of a
+// mutual TLS connection. The certificate still has UsageServerAuth if any of
+// the Domains are hosts. mkcert names the files it writes with a -client
+// suffix, such as localhost-client.pem.
+func ClientAuth(client bool) Opt {
+	return func(p *params) { p.ClientAuth = client }
+}
+
+// certUsages returns the extended key usages of cert, by the names above.
+// Usages without a name here are left out.
+func certUsages(cert *x509.Certificate) []string {
+	var usages []string
+	for _, u := range cert.ExtKeyUsage {
+		switch u {
+		case x509.ExtKeyUsageServerAuth:
+			usages = append(usages, UsageServerAuth)
+		case x509.ExtKeyUsageClientAuth:
+			usages = append(usages, UsageClientAuth)
+		case x509.ExtKeyUsageEmailProtection:
+			usages = append(usages, UsageEmailProtection)
+		}
+	}
+	return usages
+}
+
+// HasUsage reports whether the certificate has the extended key usage, such
+// as UsageClientAuth.
+func (c Cert) HasUsage(usage string) bool {
+	return hasUsage(c.Usages, usage)
+}
+
+func hasUsage(usages []string, usage string) bool {
+	for _, u := range usages {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// clientFiles returns the names mkcert -client gives the certificate and key
+// in place of the DefaultFiles cert and key.
+func clientFiles(cert, key string) (string, string) {
+	return strings.TrimSuffix(cert, ".pem") + "-client.pem", strings.TrimSuffix(key, "-key.pem") + "-client-key.pem"
+}
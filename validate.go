@@ -0,0 +1,50 @@
+package mkcert
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// OptionError is returned by Exec when its options contradict each other or
+// have invalid values, before mkcert is run.
+type OptionError struct {
+	// Options names the options at fault, such as "TempDir" and "Directory".
+	Options []string
+	// Reason is what's wrong with them.
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("mkcert: %s: %s", strings.Join(e.Options, " with "), e.Reason)
+}
+
+// validate returns an *OptionError if the options of p can't be used
+// together.
+func (p params) validate() error {
+	if p.TempDir {
+		if p.Directory != "" {
+			return &OptionError{[]string{"TempDir", "Directory"}, "TempDir chooses the directory itself"}
+		}
+		for _, f := range []struct{ opt, path string }{{"CertFile", p.CertFile}, {"KeyFile", p.KeyFile}} {
+			if filepath.IsAbs(f.path) {
+				return &OptionError{[]string{"TempDir", f.opt}, fmt.Sprintf("%s is outside the temporary directory, so wouldn't be removed by Cleanup", f.path)}
+			}
+		}
+	}
+	if p.CertFile != "" && p.KeyFile != "" && filepath.Clean(p.CertFile) == filepath.Clean(p.KeyFile) {
+		return &OptionError{[]string{"CertFile", "KeyFile"}, "the certificate and key can't be written to the same file"}
+	}
+	if p.RenewBefore < 0 {
+		return &OptionError{[]string{"RenewBefore"}, fmt.Sprintf("negative duration %s", p.RenewBefore)}
+	}
+	for _, s := range p.RequireTrustedStores {
+		if s != StoreSystem && s != StoreNSS && s != StoreJava {
+			return &OptionError{[]string{"RequireTrustedStores"}, fmt.Sprintf("unknown store %q, expected %s, %s or %s", s, StoreSystem, StoreNSS, StoreJava)}
+		}
+	}
+	if p.Keychain != "" && p.Keychain != KeychainSystem && p.Keychain != KeychainLogin {
+		return &OptionError{[]string{"Keychain"}, fmt.Sprintf("unknown keychain %q, expected %s or %s", p.Keychain, KeychainSystem, KeychainLogin)}
+	}
+	return nil
+}
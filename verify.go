@@ -0,0 +1,156 @@
+package mkcert
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Reasons a certificate fails Verify.
+const (
+	// VerifyUnreadable means the certificate or root CA couldn't be read.
+	VerifyUnreadable = "unreadable"
+	// VerifyExpired means the certificate has expired or isn't valid yet.
+	VerifyExpired = "expired"
+	// VerifyWrongCA means the certificate wasn't issued by the root CA in
+	// CARoot, typically because the CA was recreated since.
+	VerifyWrongCA = "wrong ca"
+	// VerifyHostname means the certificate doesn't cover the host name.
+	VerifyHostname = "hostname mismatch"
+	// VerifyInvalid covers the other ways verification can fail.
+	VerifyInvalid = "invalid"
+)
+
+// VerifyError is returned by Cert.Verify when the certificate fails
+// verification.
+type VerifyError struct {
+	// Reason is one of the Verify constants.
+	Reason string
+	// File is the certificate or root CA file at fault.
+	File string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("mkcert: %s: %s: %v", e.File, e.Reason, e.Err)
+}
+
+func (e *VerifyError) Unwrap() error { return e.Err }
+
+// Verify checks that the certificate in File is currently valid and was
+// issued by the root CA in CARoot. When host isn't blank, it also checks that
+// the certificate covers host. Failures are returned as a *VerifyError.
+func (c Cert) Verify(host string) error {
+	leaf, err := readCert(c.File)
+	if err != nil {
+		return &VerifyError{Reason: VerifyUnreadable, File: c.File, Err: err}
+	}
+	rootFile := filepath.Join(c.CARoot, "rootCA.pem")
+	root, err := readCert(rootFile)
+	if err != nil {
+		return &VerifyError{Reason: VerifyUnreadable, File: rootFile, Err: err}
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:   host,
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err == nil {
+		return nil
+	}
+
+	verr := &VerifyError{Reason: VerifyInvalid, File: c.File, Err: err}
+	var (
+		invalid  x509.CertificateInvalidError
+		unknown  x509.UnknownAuthorityError
+		hostname x509.HostnameError
+	)
+	switch {
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		verr.Reason = VerifyExpired
+	case errors.As(err, &unknown):
+		verr.Reason = VerifyWrongCA
+	case errors.As(err, &hostname):
+		verr.Reason = VerifyHostname
+	}
+	return verr
+}
+
+// KeyMismatchError is returned by Exec when the private key in KeyFile
+// doesn't belong to the certificate in File, such as when mkcert was
+// interrupted while writing them.
+type KeyMismatchError struct {
+	File, KeyFile string
+	// Err is why the key couldn't be read, or nil if it's simply the wrong
+	// key.
+	Err error
+}
+
+func (e *KeyMismatchError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("mkcert: key %s for %s: %v", e.KeyFile, e.File, e.Err)
+	}
+	return fmt.Sprintf("mkcert: key %s doesn't match %s", e.KeyFile, e.File)
+}
+
+func (e *KeyMismatchError) Unwrap() error { return e.Err }
+
+// checkPair returns a *KeyMismatchError unless the key in keyFile matches the
+// certificate in certFile.
+func checkPair(certFile, keyFile string) error {
+	leaf, err := readCert(certFile)
+	if err != nil {
+		return &KeyMismatchError{File: certFile, KeyFile: keyFile, Err: err}
+	}
+	key, err := readKey(keyFile)
+	if err != nil {
+		return &KeyMismatchError{File: certFile, KeyFile: keyFile, Err: err}
+	}
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(leaf.PublicKey) {
+		return &KeyMismatchError{File: certFile, KeyFile: keyFile}
+	}
+	return nil
+}
+
+// readKey parses the first private key in the PEM file at path.
+func readKey(path string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("no private key in " + path)
+		}
+		var key interface{}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+}
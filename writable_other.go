@@ -0,0 +1,13 @@
+//go:build !unix
+
+package mkcert
+
+import "os"
+
+// canWrite reports whether the process may create files in the directory
+// dir, without creating one. Only the read-only attribute is checked, so a
+// directory whose ACL denies writing is still reported writable.
+func canWrite(dir string) bool {
+	fi, err := os.Stat(dir)
+	return err == nil && fi.Mode().Perm()&0200 != 0
+}
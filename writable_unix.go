@@ -0,0 +1,11 @@
+//go:build unix
+
+package mkcert
+
+import "golang.org/x/sys/unix"
+
+// canWrite reports whether the process may create files in the directory
+// dir, without creating one.
+func canWrite(dir string) bool {
+	return unix.Access(dir, unix.W_OK) == nil
+}
@@ -0,0 +1,74 @@
+package mkcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrNotWSL is returned by InstallWindows outside of WSL.
+var ErrNotWSL = errors.New("mkcert: not running under WSL")
+
+// WSL reports whether this is running under the Windows Subsystem for Linux,
+// where browsers on the Windows host don't use the Linux trust stores.
+func WSL() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	b, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && bytes.Contains(bytes.ToLower(b), []byte("microsoft"))
+}
+
+// InstallWindows installs the CA used in WSL into the trust store of the
+// Windows host, so that Windows browsers trust the certificates issued
+// inside WSL. It uses the Windows mkcert.exe if it's in PATH, pointing its
+// CAROOT at the Linux one, and otherwise adds the root CA to the current
+// user's store with Windows' certutil.exe. Windows may ask the user to
+// confirm.
+func InstallWindows(opts ...Opt) error {
+	if !WSL() {
+		return ErrNotWSL
+	}
+	var p params
+	for _, o := range opts {
+		o(&p)
+	}
+	trust, err := trustStatus(p)
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("mkcert.exe"); err == nil {
+		// WSLENV passes CAROOT to Windows, translating it into a Windows
+		// path with /p.
+		wslenv := "CAROOT/p"
+		if env := os.Getenv("WSLENV"); env != "" {
+			wslenv = env + ":" + wslenv
+		}
+		cmd = exec.Command("mkcert.exe", "-install")
+		cmd.Env = append(p.environ(), "CAROOT="+trust.CARoot, "WSLENV="+wslenv)
+	} else {
+		out, err := exec.Command("wslpath", "-w", filepath.Join(trust.CARoot, "rootCA.pem")).Output()
+		if err != nil {
+			return fmt.Errorf("mkcert: wslpath: %w", err)
+		}
+		cmd = exec.Command("certutil.exe", "-user", "-addstore", "Root", strings.TrimSpace(string(out)))
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if perr, ok := err.(*exec.ExitError); ok {
+			perr.Stderr = out
+		}
+		return fmt.Errorf("mkcert: %s: %w", filepath.Base(cmd.Path), err)
+	}
+	return nil
+}